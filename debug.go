@@ -0,0 +1,224 @@
+package gdutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moul/http2curl"
+)
+
+//debugLevel is level of every message written to debug output in structured mode
+const debugLevel = "debug"
+
+const (
+	//colorRed is ANSI escape code used for removed lines of diff
+	colorRed = "\033[31m"
+	//colorGreen is ANSI escape code used for added lines of diff
+	colorGreen = "\033[32m"
+	//colorReset is ANSI escape code which resets color of output
+	colorReset = "\033[0m"
+)
+
+//debugEntry represents single line of debug output in structured mode
+type debugEntry struct {
+	Time   string                 `json:"time"`
+	Level  string                 `json:"level"`
+	Msg    string                 `json:"msg"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+//RecordingDebugger is debug output that keeps written debug messages in memory.
+//It may be passed to Scenario.SetDebugOutput to inspect debug messages of steps in tests.
+type RecordingDebugger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+//Write records p as single debug message, trailing new lines are omitted.
+func (r *RecordingDebugger) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if msg == "" {
+		return len(p), nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+
+	return len(p), nil
+}
+
+//Messages returns all recorded debug messages in order of writing.
+func (r *RecordingDebugger) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := make([]string, len(r.messages))
+	copy(messages, r.messages)
+
+	return messages
+}
+
+//LastDebugMessages returns debug messages recorded by scenario debug output,
+//nil is returned if debug output is not RecordingDebugger.
+func (s *Scenario) LastDebugMessages() []string {
+	recorder, ok := s.debugOutput.(*RecordingDebugger)
+	if !ok {
+		return nil
+	}
+
+	return recorder.Messages()
+}
+
+//debugf writes formatted message to debug output
+func (s *Scenario) debugf(format string, a ...interface{}) {
+	if s.structuredDebug {
+		s.debugStructured(strings.TrimSpace(fmt.Sprintf(format, a...)), nil)
+		return
+	}
+
+	fmt.Fprintf(s.debugOutput, format, a...)
+}
+
+//debugRequest writes provided HTTP request as cURL command to debug output
+func (s *Scenario) debugRequest(req *http.Request) {
+	command, _ := http2curl.GetCurlCommand(req)
+	if s.structuredDebug {
+		s.debugStructured("request", map[string]interface{}{"curl": command.String()})
+		return
+	}
+
+	fmt.Fprintln(s.debugOutput, command)
+}
+
+//debugResponse writes last HTTP response body to debug output
+func (s *Scenario) debugResponse() {
+	if s.structuredDebug {
+		s.debugStructured("response", map[string]interface{}{
+			"status": s.lastResponse.StatusCode,
+			"body":   s.debugBodyField(),
+		})
+		return
+	}
+
+	fmt.Fprintf(s.debugOutput, "Response body:\n\n")
+	s.printLastResponseBody(s.debugOutput)
+	fmt.Fprintf(s.debugOutput, "\n")
+}
+
+//debugLastResponseBody writes last HTTP response body to debug output
+func (s *Scenario) debugLastResponseBody() {
+	if s.structuredDebug {
+		s.debugStructured("last response body", map[string]interface{}{"body": s.debugBodyField()})
+		return
+	}
+
+	s.printLastResponseBody(s.debugOutput)
+}
+
+//debugBodyField returns last response body in form suitable for structured debug field,
+//JSON body is embedded as is, any other body as string
+func (s *Scenario) debugBodyField() interface{} {
+	body := s.GetLastResponseBody()
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+
+	return string(body)
+}
+
+//debugDiff writes line by line difference between expected and actual value to debug output.
+//Lines present only in expected value are prefixed with "-", lines present only in actual value with "+".
+func (s *Scenario) debugDiff(expected, actual string) {
+	diff := lineDiff(expected, actual)
+	if s.structuredDebug {
+		s.debugStructured("diff", map[string]interface{}{"diff": diff})
+		return
+	}
+
+	colorize := s.colorizedDebug && os.Getenv("NO_COLOR") == ""
+	fmt.Fprintf(s.debugOutput, "Diff (-expected +actual):\n")
+	for _, line := range diff {
+		switch {
+		case colorize && strings.HasPrefix(line, "-"):
+			fmt.Fprintln(s.debugOutput, colorRed+line+colorReset)
+		case colorize && strings.HasPrefix(line, "+"):
+			fmt.Fprintln(s.debugOutput, colorGreen+line+colorReset)
+		default:
+			fmt.Fprintln(s.debugOutput, line)
+		}
+	}
+}
+
+//lineDiff returns lines of expected and actual strings prefixed with "-" when line was removed,
+//"+" when line was added and " " when line is present in both strings
+func lineDiff(expected, actual string) []string {
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+
+	//lcs[i][j] holds length of longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+
+	return diff
+}
+
+//debugStructured writes message with fields to debug output as single JSON line
+func (s *Scenario) debugStructured(msg string, fields map[string]interface{}) {
+	line, err := json.Marshal(debugEntry{
+		Time:   s.now().Format(time.RFC3339Nano),
+		Level:  debugLevel,
+		Msg:    msg,
+		Fields: fields,
+	})
+	if err != nil {
+		fmt.Fprintf(s.debugOutput, "could not marshal debug message %s: %s\n", msg, err)
+		return
+	}
+
+	fmt.Fprintln(s.debugOutput, string(line))
+}
@@ -0,0 +1,150 @@
+package gdutils
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+//arithmeticParser evaluates arithmetic expressions with operators + - * /, parentheses, numbers
+//and identifiers referencing numeric values. It uses recursive descent over grammar:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = factor { ("*" | "/") factor }
+//	factor     = ["-"] ( number | identifier | "(" expression ")" )
+type arithmeticParser struct {
+	input []rune
+	pos   int
+	//resolve returns numeric value of identifier
+	resolve func(identifier string) (float64, error)
+}
+
+//evaluateArithmetic evaluates arithmetic expression, identifiers are resolved with resolve
+func evaluateArithmetic(expression string, resolve func(identifier string) (float64, error)) (float64, error) {
+	p := &arithmeticParser{input: []rune(expression), resolve: resolve}
+	value, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d of expression %s", p.input[p.pos], p.pos, expression)
+	}
+
+	return value, nil
+}
+
+//expression parses sum or difference of terms
+func (p *arithmeticParser) expression() (float64, error) {
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '+' && p.input[p.pos] != '-') {
+			return value, nil
+		}
+
+		operator := p.input[p.pos]
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return 0, err
+		}
+
+		if operator == '+' {
+			value += right
+		} else {
+			value -= right
+		}
+	}
+}
+
+//term parses product or quotient of factors
+func (p *arithmeticParser) term() (float64, error) {
+	value, err := p.factor()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '*' && p.input[p.pos] != '/') {
+			return value, nil
+		}
+
+		operator := p.input[p.pos]
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return 0, err
+		}
+
+		if operator == '*' {
+			value *= right
+			continue
+		}
+
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero at position %d", p.pos)
+		}
+		value /= right
+	}
+}
+
+//factor parses number, identifier or expression in parentheses, optionally negated
+func (p *arithmeticParser) factor() (float64, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	current := p.input[p.pos]
+	switch {
+	case current == '-':
+		p.pos++
+		value, err := p.factor()
+
+		return -value, err
+	case current == '(':
+		p.pos++
+		value, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis at position %d", p.pos)
+		}
+		p.pos++
+
+		return value, nil
+	case unicode.IsDigit(current) || current == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+
+		return strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+	case unicode.IsLetter(current) || current == '_':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '_') {
+			p.pos++
+		}
+
+		return p.resolve(string(p.input[start:p.pos]))
+	default:
+		return 0, fmt.Errorf("unexpected %q at position %d", current, p.pos)
+	}
+}
+
+//skipSpaces moves position past white spaces
+func (p *arithmeticParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
@@ -1,26 +1,50 @@
 package gdutils
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/tls"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
 	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cucumber/godog"
-	"github.com/moul/http2curl"
 	"github.com/pawelWritesCode/qjson"
 )
 
 const (
 	typeJSON = "JSON"
+	typeXML  = "XML"
+
+	//redactedValue replaces values of redacted JSON nodes
+	redactedValue = "***"
+
+	//flowStartCacheKey is cache key under which IStartFlowTimer preserves start time of flow
+	flowStartCacheKey = "FLOW_START_TIME"
+
+	//requestDurationsCacheKey is cache key under which ISendRequestToTimesAndAssertP95 preserves durations of requests
+	requestDurationsCacheKey = "REQUEST_DURATIONS"
 )
 
 //bodyHeaders is entity that holds information about request body and request headers
@@ -32,452 +56,2202 @@ type bodyHeaders struct {
 //ISendRequestToWithBodyAndHeaders sends HTTP request with provided body and headers.
 //Argument method indices HTTP request method for example: "POST", "GET" etc.
 //Argument urlTemplate should be full url path. May include template values.
-//Argument bodyTemplate should be slice of bytes marshallable on bodyHeaders struct
+//Argument bodyTemplate should be slice of bytes marshallable on bodyHeaders struct.
+//Body is sent as JSON. With Content-Type auto detection enabled, string body is sent as is, e.g. XML document
 func (s *Scenario) ISendRequestToWithBodyAndHeaders(method, urlTemplate string, bodyTemplate *godog.DocString) error {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	client := &http.Client{Transport: tr}
-	input, err := s.replaceTemplatedValue(bodyTemplate.Content)
+	req, err := s.buildRequest(method, urlTemplate, bodyTemplate)
 	if err != nil {
 		return err
 	}
 
-	url, err := s.replaceTemplatedValue(urlTemplate)
-	if err != nil {
-		return err
-	}
+	return s.sendRequest(req)
+}
 
-	var bodyAndHeaders bodyHeaders
-	err = json.Unmarshal([]byte(input), &bodyAndHeaders)
+//ISendMultipartRequestTo sends HTTP request with multipart/form-data body, e.g. to upload files.
+//Argument bodyTemplate should be slice of bytes marshallable on bodyHeaders struct, with body being JSON object
+//of form fields. Values prefixed with file:// are streamed as content of referenced files, other strings, numbers and booleans
+//are sent as form values. Streamed form can not be signed with AWS SigV4.
+func (s *Scenario) ISendMultipartRequestTo(method, urlTemplate string, bodyTemplate *godog.DocString) error {
+	req, err := s.buildMultipartRequest(method, urlTemplate, bodyTemplate)
 	if err != nil {
 		return err
 	}
+	//closing body releases form writer when request was not sent
+	defer req.Body.Close()
 
-	reqBody, err := json.Marshal(bodyAndHeaders.Body)
-	if err != nil {
-		return err
-	}
+	return s.sendRequest(req)
+}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+//ISendRequestToWithBodyAndHeadersAndDeadlineFrom sends HTTP request with provided body and headers,
+//request is cancelled when deadline preserved in cache under deadlineCacheKey is reached.
+//Argument deadlineCacheKey should point at time.Time value.
+func (s *Scenario) ISendRequestToWithBodyAndHeadersAndDeadlineFrom(method, urlTemplate, deadlineCacheKey string, bodyTemplate *godog.DocString) error {
+	deadline, err := s.GetSavedTime(deadlineCacheKey)
 	if err != nil {
 		return err
 	}
 
-	for headerName, headerValue := range bodyAndHeaders.Headers {
-		req.Header.Set(headerName, headerValue)
-	}
-
-	if s.isDebug {
-		command, _ := http2curl.GetCurlCommand(req)
-		fmt.Println(command)
-	}
-
-	resp, err := client.Do(req)
+	req, err := s.buildRequest(method, urlTemplate, bodyTemplate)
 	if err != nil {
 		return err
 	}
 
-	s.lastResponse = resp
-	//err = s.saveLastResponseCredentials(resp)
-	if s.isDebug {
-		fmt.Printf("Response body:\n\n")
-		_ = s.IPrintLastResponseBody()
-		fmt.Printf("\n")
+	ctx, cancel := context.WithDeadline(req.Context(), deadline)
+	defer cancel()
+
+	err = s.sendRequest(req.WithContext(ctx))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("request timed out, deadline %s exceeded: %w", deadline.Format(time.RFC3339Nano), err)
 	}
 
 	return err
 }
 
-//TheResponseStatusCodeShouldBe compare last response status code with given in argument.
-func (s *Scenario) TheResponseStatusCodeShouldBe(code int) error {
-	if s.lastResponse.StatusCode != code {
-		return fmt.Errorf("%w, expected: %d, actual: %d",
-			ErrResponseCode, code, s.lastResponse.StatusCode)
+//ISendRequestToWithBodyAndHeadersWithBackoff sends HTTP request with provided body and headers,
+//retrying up to maxAttempts times on network errors. Delay between attempts starts at baseDelay and doubles after each attempt.
+//Any HTTP response, regardless of its status code, stops retrying. Errors other than network errors, e.g. exceeded
+//response body size limit, failed request signing or unsupported protocol scheme, are returned immediately, because retrying would not help.
+//Argument baseDelay should be string valid for time.ParseDuration func
+func (s *Scenario) ISendRequestToWithBodyAndHeadersWithBackoff(method, urlTemplate string, maxAttempts int, baseDelay string, bodyTemplate *godog.DocString) error {
+	if maxAttempts < 1 {
+		return fmt.Errorf("max attempts %d can't be less than 1", maxAttempts)
 	}
 
-	return nil
-}
-
-//TheResponseShouldBeIn checks whether last response body has given data type
-//available data types are listed in switch section
-func (s *Scenario) TheResponseShouldBeIn(dataType string) error {
-	switch dataType {
-	case typeJSON:
-		return s.theResponseShouldBeInJSON()
-	default:
-		return fmt.Errorf("unknown data type, available values: %s", typeJSON)
+	delay, err := time.ParseDuration(baseDelay)
+	if err != nil {
+		return err
 	}
-}
 
-//ISaveFromTheLastResponseJSONNodeAs saves from last response json node under given variableName.
-func (s *Scenario) ISaveFromTheLastResponseJSONNodeAs(node, variableName string) error {
-	iVal, err := qjson.Resolve(node, s.GetLastResponseBody())
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		req, err := s.buildRequest(method, urlTemplate, bodyTemplate)
+		if err != nil {
+			return err
+		}
+
+		lastErr = s.sendRequest(req)
+		if lastErr == nil {
+			return nil
+		}
+
+		if !isNetworkError(lastErr) {
+			return lastErr
+		}
 
-	if err != nil {
 		if s.isDebug {
-			_ = s.IPrintLastResponseBody()
+			s.debugf("attempt %d of %d failed: %s\n", attempt, maxAttempts, lastErr)
 		}
 
-		return err
+		if attempt < maxAttempts {
+			time.Sleep(s.withJitter(delay))
+			delay *= 2
+		}
 	}
 
-	s.Save(variableName, iVal)
-
-	return nil
+	return fmt.Errorf("request failed after %d attempts, last error: %w", maxAttempts, lastErr)
 }
 
-//IGenerateARandomIntInTheRangeToAndSaveItAs generates random integer from provided range and preserve it under given name in cache
-func (s *Scenario) IGenerateARandomIntInTheRangeToAndSaveItAs(from, to int, name string) error {
-	s.Save(name, randomInt(from, to))
+//ISendRequestToWithBodyAndHeadersTwice sends the same HTTP request with provided body and headers two times in a row,
+//both responses may be compared with TheTwoResponsesShouldBeIdentical
+func (s *Scenario) ISendRequestToWithBodyAndHeadersTwice(method, urlTemplate string, bodyTemplate *godog.DocString) error {
+	for i := 0; i < 2; i++ {
+		if err := s.ISendRequestToWithBodyAndHeaders(method, urlTemplate, bodyTemplate); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-//IGenerateARandomFloatInTheRangeToAndSaveItAs generates random float from provided range and preserve it under given name in cache
-func (s *Scenario) IGenerateARandomFloatInTheRangeToAndSaveItAs(from, to int, name string) error {
-	randInt := randomInt(from, to)
-	float01 := rand.Float64()
-
-	strFloat := fmt.Sprintf("%.2f", float01*float64(randInt))
-	floatVal, err := strconv.ParseFloat(strFloat, 64)
+//ISendRequestToTimesAndAssertP95 sends the same HTTP request with provided body and headers n times in a row
+//and checks whether 95th percentile of their durations is lower than p95. Durations are saved in cache
+//as []time.Duration under key REQUEST_DURATIONS. Argument p95 should be compatible with time.ParseDuration
+func (s *Scenario) ISendRequestToTimesAndAssertP95(method, urlTemplate string, n int, p95 string, bodyTemplate *godog.DocString) error {
+	threshold, err := time.ParseDuration(p95)
 	if err != nil {
 		return err
 	}
 
-	s.Save(name, floatVal)
+	if n < 1 {
+		return fmt.Errorf("number of requests %d should be positive", n)
+	}
 
-	return nil
-}
+	durations := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		req, err := s.buildRequest(method, urlTemplate, bodyTemplate)
+		if err != nil {
+			return err
+		}
 
-//IGenerateARandomStringOfLengthWithoutUnicodeCharactersAndSaveItAs generates random string of given length without unicode characters
-func (s *Scenario) IGenerateARandomStringOfLengthWithoutUnicodeCharactersAndSaveItAs(strLength int, key string) error {
-	if strLength <= 0 {
-		return fmt.Errorf("provided string length %d can't be less than 1", strLength)
+		start := time.Now()
+		if err = s.sendRequest(req); err != nil {
+			return err
+		}
+		durations = append(durations, time.Since(start))
 	}
 
-	s.Save(key, s.stringWithCharset(strLength, charsetLettersOnly))
+	s.Save(requestDurationsCacheKey, durations)
 
-	return nil
-}
+	sorted := make([]time.Duration, n)
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
 
-//IGenerateARandomStringOfLengthWithUnicodeCharactersAndSaveItAs generates random string of given length with unicode characters
-func (s *Scenario) IGenerateARandomStringOfLengthWithUnicodeCharactersAndSaveItAs(strLength int, key string) error {
-	if strLength <= 0 {
-		return fmt.Errorf("provided string length %d can't be less than 1", strLength)
+	//nearest-rank method
+	percentile := sorted[int(math.Ceil(0.95*float64(n)))-1]
+	if percentile >= threshold {
+		return fmt.Errorf("95th percentile of %d requests durations is %s, expected lower than %s", n, percentile, threshold)
 	}
 
-	s.Save(key, s.stringWithCharset(strLength, charsetUnicodeCharacters))
-
 	return nil
 }
 
-//TheJSONResponseShouldHaveKey checks whether last response body contains given key
-func (s *Scenario) TheJSONResponseShouldHaveKey(key string) error {
-	_, err := qjson.Resolve(key, s.GetLastResponseBody())
+//TheStreamedJSONLinesNodeShouldBeIncreasing sends GET request to urlTemplate and consumes streamed response,
+//in which every line is separate JSON document, for timeInterval or until stream ends.
+//Numeric node from expr should never decrease across consumed lines. Empty lines are skipped.
+//timeInterval should be compatible with time.ParseDuration. Request is sent with settings of scenario, e.g. Basic Auth or request timeout
+func (s *Scenario) TheStreamedJSONLinesNodeShouldBeIncreasing(urlTemplate, expr, timeInterval string) error {
+	duration, err := time.ParseDuration(timeInterval)
 	if err != nil {
-		if s.isDebug {
-			_ = s.IPrintLastResponseBody()
-		}
+		return err
+	}
 
-		return fmt.Errorf("%v, missing key '%s'", ErrJsonNode, key)
+	url, err := s.replaceTemplatedValue(urlTemplate)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
 
-//TheJSONNodeShouldNotBe checks whether JSON node from last response body is not of provided type
-//goType may be one of: nil, string, int, float, bool, map, slice
-//node should be expression acceptable by qjson package against JSON node from last response body
-func (s *Scenario) TheJSONNodeShouldNotBe(node string, goType string) error {
-	iNodeVal, err := qjson.Resolve(node, s.GetLastResponseBody())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
 
-	vNodeVal := reflect.ValueOf(iNodeVal)
-	errInvalidType := fmt.Errorf("%s value is \"%s\", but expected not to be", node, goType)
-	switch goType {
-	case "nil":
-		if !vNodeVal.IsValid() || valueIsNil(vNodeVal) {
-			return errInvalidType
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var previous float64
+	lines := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
 		}
 
-		return nil
-	case "string":
-		if vNodeVal.Kind() == reflect.String {
-			return errInvalidType
+		iValue, err := qjson.Resolve(expr, line)
+		if err != nil {
+			return fmt.Errorf("line %d of stream: %w", lines+1, err)
 		}
 
-		return nil
-	case "int":
-		if vNodeVal.Kind() == reflect.Int64 || vNodeVal.Kind() == reflect.Int32 || vNodeVal.Kind() == reflect.Int16 ||
-			vNodeVal.Kind() == reflect.Int8 || vNodeVal.Kind() == reflect.Int || vNodeVal.Kind() == reflect.Uint ||
-			vNodeVal.Kind() == reflect.Uint8 || vNodeVal.Kind() == reflect.Uint16 || vNodeVal.Kind() == reflect.Uint32 ||
-			vNodeVal.Kind() == reflect.Uint64 {
-			return errInvalidType
+		value, ok := iValue.(float64)
+		if !ok {
+			return fmt.Errorf("%w: node %s in line %d of stream is %T, expected number", ErrJsonNode, expr, lines+1, iValue)
 		}
 
-		if vNodeVal.Kind() == reflect.Float64 {
-			_, frac := math.Modf(vNodeVal.Float())
-			if frac == 0 {
-				return errInvalidType
-			}
+		if lines > 0 && value < previous {
+			return fmt.Errorf("node %s decreased from %v to %v in line %d of stream", expr, previous, value, lines+1)
 		}
 
-		return nil
-	case "float":
-		if vNodeVal.Kind() == reflect.Float64 || vNodeVal.Kind() == reflect.Float32 {
-			_, frac := math.Modf(vNodeVal.Float())
-			if frac == 0 {
-				return nil
-			}
+		previous = value
+		lines++
+	}
 
-			return errInvalidType
-		}
+	//stream interrupted by reaching timeInterval is consumed successfully
+	if err = scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
 
-		return nil
-	case "bool":
-		if vNodeVal.Kind() == reflect.Bool {
-			return errInvalidType
-		}
+	if lines == 0 {
+		return fmt.Errorf("no JSON lines were streamed from %s in %s", url, duration)
+	}
 
-		return nil
-	case "map":
-		if vNodeVal.Kind() == reflect.Map {
-			return errInvalidType
-		}
+	return nil
+}
 
-		return nil
-	case "slice":
-		if vNodeVal.Kind() == reflect.Slice {
-			return errInvalidType
+//TheStreamedJSONArrayAtShouldHaveAtLeastElements sends GET request to urlTemplate and checks whether array
+//pointed by arrayPath in response body has at least n elements. Response body is consumed as stream of JSON tokens,
+//so huge documents are not loaded into memory and reading stops after n elements.
+//Empty arrayPath points at root of document. Request is sent with settings of scenario, e.g. Basic Auth or request timeout
+func (s *Scenario) TheStreamedJSONArrayAtShouldHaveAtLeastElements(urlTemplate, arrayPath string, n int) error {
+	var steps []jsonPathStep
+	if arrayPath != "" {
+		var err error
+		if steps, err = parseJSONPath(arrayPath); err != nil {
+			return err
 		}
+	}
 
-		return nil
-	default:
-		return fmt.Errorf("%s is unknown type for this step", goType)
+	url, err := s.replaceTemplatedValue(urlTemplate)
+	if err != nil {
+		return err
 	}
-}
 
-//TheJSONNodeShouldBe checks whether JSON node from last response body is of provided type
-//goType may be one of: nil, string, int, float, bool, map, slice
-//node should be expression acceptable by qjson package against JSON node from last response body
-func (s *Scenario) TheJSONNodeShouldBe(node string, goType string) error {
-	errInvalidType := fmt.Errorf("%s value is not \"%s\", but expected to be", node, goType)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
 
-	switch goType {
-	case "nil", "string", "int", "float", "bool", "map", "slice":
-		if err := s.TheJSONNodeShouldNotBe(node, goType); err == nil {
-			return errInvalidType
-		}
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-		return nil
-	default:
-		return fmt.Errorf("%s is unknown type for this step", goType)
+	count, err := countStreamedJSONArray(resp.Body, steps, n)
+	if err != nil {
+		return fmt.Errorf("could not count elements of node %s streamed from %s: %w", arrayPath, url, err)
+	}
+
+	if count < n {
+		return fmt.Errorf("node %s streamed from %s has %d elements, expected at least %d", arrayPath, url, count, n)
 	}
+
+	return nil
 }
 
-//TheJSONResponseShouldHaveKeys checks whether last request body has keys defined in string separated by comma
-func (s *Scenario) TheJSONResponseShouldHaveKeys(keys string) error {
-	keysSlice := strings.Split(keys, ",")
+//IEnableContentTypeAutoDetection turns on setting Content-Type header of next requests in scenario based on their body format.
+//Header is set only when request does not define Content-Type on its own. String bodies are sent as is, instead of as JSON strings.
+//JSON and XML are recognised explicitly, YAML is not detected, other formats are recognised by http.DetectContentType
+func (s *Scenario) IEnableContentTypeAutoDetection() error {
+	s.autoContentType = true
 
-	errs := make([]error, 0, len(keysSlice))
-	for _, key := range keysSlice {
-		trimmedKey := strings.TrimSpace(key)
-		_, err := qjson.Resolve(trimmedKey, s.GetLastResponseBody())
+	return nil
+}
 
-		if err != nil {
-			errs = append(errs, fmt.Errorf("missing key %s", trimmedKey))
-		}
+//IEnableDryRun turns on dry run mode for current scenario. Requests are built, but not sent,
+//and every response has status 200 and empty body
+func (s *Scenario) IEnableDryRun() error {
+	s.dryRunResponseBody = []byte{}
+
+	return nil
+}
+
+//IEnableDryRunWithResponseBody turns on dry run mode for current scenario. Requests are built, but not sent,
+//and every response has status 200 and templated body, so assertions on body may be satisfied
+func (s *Scenario) IEnableDryRunWithResponseBody(bodyTemplate *godog.DocString) error {
+	body, err := s.replaceTemplatedValue(bodyTemplate.Content)
+	if err != nil {
+		return err
 	}
 
-	if len(errs) > 0 {
-		var errString string
-		for _, err := range errs {
-			errString += fmt.Sprintf("%s\n", err)
-		}
+	s.dryRunResponseBody = []byte(body)
 
-		if s.isDebug {
-			_ = s.IPrintLastResponseBody()
-		}
+	return nil
+}
 
-		return errors.New(errString)
+//ISetFollowingQueryParamsForNextRequest adds query parameters to URL of next sent request, parameters already present
+//in URL are preserved. Argument paramsTemplate should be JSON object with parameter names as keys and string values,
+//values are escaped, so they should not be URL encoded
+func (s *Scenario) ISetFollowingQueryParamsForNextRequest(paramsTemplate *godog.DocString) error {
+	paramsJSON, err := s.replaceTemplatedValue(paramsTemplate.Content)
+	if err != nil {
+		return err
+	}
+
+	var params map[string]string
+	if err = json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+		return fmt.Errorf("query params have %w: %v", ErrJson, err)
+	}
+
+	s.nextQueryParams = url.Values{}
+	for name, value := range params {
+		s.nextQueryParams.Add(name, value)
 	}
 
 	return nil
 }
 
-//IPrintLastResponseBody prints last response from request
-func (s *Scenario) IPrintLastResponseBody() error {
-	var tmp map[string]interface{}
-	err := json.Unmarshal(s.GetLastResponseBody(), &tmp)
+//IRemoveHeaderFromNextRequest removes header with given name from next sent request, e.g. to check whether server rejects
+//requests without required header. Header is removed after Basic Auth is set and before request is signed with AWS SigV4.
+//Removing header absent in request does nothing
+func (s *Scenario) IRemoveHeaderFromNextRequest(headerName string) error {
+	s.nextRemovedHeaders = append(s.nextRemovedHeaders, headerName)
 
-	if err != nil {
-		fmt.Println(string(s.GetLastResponseBody()))
-		return nil
-	}
+	return nil
+}
 
-	indentedRespBody, err := json.MarshalIndent(tmp, "", "\t")
+//ISetCookieForNextRequest adds cookie with given name and templated value to next sent request,
+//e.g. to reuse session cookie saved in cache without sending login request
+func (s *Scenario) ISetCookieForNextRequest(name, valueTemplate string) error {
+	if name == "" {
+		return errors.New("cookie name can't be empty")
+	}
 
+	value, err := s.replaceTemplatedValue(valueTemplate)
 	if err != nil {
-		fmt.Println(string(s.GetLastResponseBody()))
-		return nil
+		return err
 	}
 
-	fmt.Println(string(indentedRespBody))
+	s.nextCookies = append(s.nextCookies, &http.Cookie{Name: name, Value: value})
+
 	return nil
 }
 
-//TheJSONNodeShouldBeSliceOfLength checks whether given key is slice and has given length
-func (s *Scenario) TheJSONNodeShouldBeSliceOfLength(expr string, length int) error {
-	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+//ISetCannedResponseForNextSend makes next sent request return response with given status, body and headers
+//instead of sending it. Response is handled like any other, so all assertion steps work on it.
+//Argument headersTemplate should be empty or JSON object with header names as keys
+func (s *Scenario) ISetCannedResponseForNextSend(status int, bodyTemplate, headersTemplate string) error {
+	body, err := s.replaceTemplatedValue(bodyTemplate)
 	if err != nil {
-		if s.isDebug {
-			_ = s.IPrintLastResponseBody()
-		}
-
 		return err
 	}
 
-	v := reflect.ValueOf(iValue)
-	if v.Kind() == reflect.Slice {
-		if v.Len() != length {
-			return fmt.Errorf("%s slice has length: %d, expected: %d", expr, v.Len(), length)
+	header := http.Header{}
+	if strings.TrimSpace(headersTemplate) != "" {
+		headersJSON, err := s.replaceTemplatedValue(headersTemplate)
+		if err != nil {
+			return err
 		}
 
-		return nil
-	}
-	if s.isDebug {
-		_ = s.IPrintLastResponseBody()
+		var headers map[string]string
+		if err = json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+			return fmt.Errorf("headers have %w: %v", ErrJson, err)
+		}
+
+		for name, value := range headers {
+			header.Set(name, value)
+		}
 	}
 
-	return fmt.Errorf("%s is not slice", expr)
+	s.cannedResponse = &dryRunTransport{statusCode: status, header: header, body: []byte(body)}
+
+	return nil
 }
 
-//TheJSONNodeShouldBeOfValue compares json node value from expression to expected by user dataValue of given by user dataType
-//available data types are listed in switch section in each case directive
-func (s *Scenario) TheJSONNodeShouldBeOfValue(expr, dataType, dataValue string) error {
-	nodeValueReplaced, err := s.replaceTemplatedValue(dataValue)
+//IReplayFromHARFile makes requests sent in current scenario return responses recorded in HTTP Archive (HAR) file
+//instead of being sent. Requests are matched with recorded ones by method and URL, unmatched requests fail.
+//fileReference should be path to file, optionally prefixed with file://
+func (s *Scenario) IReplayFromHARFile(fileReference string) error {
+	filePath := strings.TrimPrefix(fileReference, "file://")
+	fileContent, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	if s.isDebug {
-		fmt.Printf("Replaced value: %s\n", nodeValueReplaced)
+	var har harFile
+	if err = json.Unmarshal(fileContent, &har); err != nil {
+		return fmt.Errorf("file %s has %w: %v", filePath, ErrJson, err)
 	}
 
-	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	s.harReplay, err = newHARReplayTransport(har)
+
+	return err
+}
+
+//ISetBasicAuth sets HTTP Basic Auth with username and password on next requests in current scenario.
+//Arguments username and password may be templated, e.g. to use credentials saved in cache.
+//Basic Auth is replaced by signature if requests are signed with AWS SigV4
+func (s *Scenario) ISetBasicAuth(username, password string) error {
+	username, err := s.replaceTemplatedValue(username)
 	if err != nil {
-		if s.isDebug {
-			_ = s.IPrintLastResponseBody()
-		}
+		return err
+	}
 
+	password, err = s.replaceTemplatedValue(password)
+	if err != nil {
 		return err
 	}
 
-	switch dataType {
-	case "string":
-		strVal, ok := iValue.(string)
-		if !ok {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
+	s.basicAuth = &[2]string{username, password}
+
+	return nil
+}
+
+//ISetAWSSigV4Signing turns on signing of next requests in current scenario with AWS Signature Version 4.
+//Arguments accessKey and secretKey may be templated, e.g. to use credentials from environment
+func (s *Scenario) ISetAWSSigV4Signing(accessKey, secretKey, region, service string) error {
+	accessKey, err := s.replaceTemplatedValue(accessKey)
+	if err != nil {
+		return err
+	}
+
+	secretKey, err = s.replaceTemplatedValue(secretKey)
+	if err != nil {
+		return err
+	}
+
+	s.awsSigV4 = &awsSigV4Credentials{accessKey: accessKey, secretKey: secretKey, region: region, service: service}
+
+	return nil
+}
+
+//IPrintCanonicalRequest writes to debug output canonical form of last request signed with AWS Signature Version 4.
+//Server rejecting signature should compute the same canonical request, so it helps to find signing differences
+func (s *Scenario) IPrintCanonicalRequest() error {
+	if s.lastCanonicalRequest == "" {
+		return errors.New("no request was signed in current scenario")
+	}
+
+	s.debugf("Canonical request:\n%s\n", s.lastCanonicalRequest)
+
+	return nil
+}
+
+//ISetStructuredDebug turns on or off writing debug messages as JSON lines with timestamp and level.
+//Argument enabled should be string acceptable by strconv.ParseBool func
+func (s *Scenario) ISetStructuredDebug(enabled string) error {
+	isEnabled, err := strconv.ParseBool(enabled)
+	if err != nil {
+		return fmt.Errorf("could not convert %s to bool: %w", enabled, err)
+	}
+
+	s.structuredDebug = isEnabled
+
+	return nil
+}
+
+//ISetColorizedDebug turns on or off coloring of removed and added lines of diffs written to debug output.
+//Colors are never used when NO_COLOR environment variable is set.
+//Argument enabled should be string acceptable by strconv.ParseBool func
+func (s *Scenario) ISetColorizedDebug(enabled string) error {
+	isEnabled, err := strconv.ParseBool(enabled)
+	if err != nil {
+		return fmt.Errorf("could not convert %s to bool: %w", enabled, err)
+	}
+
+	s.colorizedDebug = isEnabled
+
+	return nil
+}
+
+//TheResponseStatusCodeShouldBe compare last response status code with given in argument.
+func (s *Scenario) TheResponseStatusCodeShouldBe(code int) error {
+	if s.lastResponse.StatusCode != code {
+		return fmt.Errorf("%w, expected: %d, actual: %d",
+			ErrResponseCode, code, s.lastResponse.StatusCode)
+	}
+
+	return nil
+}
+
+//TheResponseStatusTextShouldBe compares reason phrase of last response status, e.g. "Not Found", with text.
+//Comparison is case-insensitive
+func (s *Scenario) TheResponseStatusTextShouldBe(text string) error {
+	statusText := strings.TrimSpace(strings.TrimPrefix(s.lastResponse.Status, strconv.Itoa(s.lastResponse.StatusCode)))
+	if !strings.EqualFold(statusText, strings.TrimSpace(text)) {
+		return fmt.Errorf("last response status text %q is not equal to expected %q", statusText, text)
+	}
+
+	return nil
+}
+
+//TheCreatedResourceShouldBeRetrievable sends GET request to URL from Location header of last response
+//and checks whether its status code is expectedStatus. Relative Location is resolved against URL of last request.
+//Response of GET request becomes last response, so next steps may check its body
+func (s *Scenario) TheCreatedResourceShouldBeRetrievable(expectedStatus int) error {
+	location := s.lastResponse.Header.Get("Location")
+	if location == "" {
+		return errors.New("last HTTP response has no Location header")
+	}
+
+	if s.lastResponse.Request == nil || s.lastResponse.Request.URL == nil {
+		return errors.New("last response has no request")
+	}
+
+	resourceURL, err := s.lastResponse.Request.URL.Parse(location)
+	if err != nil {
+		return fmt.Errorf("could not parse Location header %s: %w", location, err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, resourceURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if err = s.sendRequest(req); err != nil {
+		return err
+	}
+
+	return s.TheResponseStatusCodeShouldBe(expectedStatus)
+}
+
+//IFollowJSONNodeLink sends GET request to URL from string JSON node from last response body.
+//Relative URL is resolved against URL of last request. Response of GET request becomes last response,
+//so next steps may check it or follow its links
+func (s *Scenario) IFollowJSONNodeLink(expr string) error {
+	link, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	if s.lastResponse.Request == nil || s.lastResponse.Request.URL == nil {
+		return errors.New("last response has no request")
+	}
+
+	linkURL, err := s.lastResponse.Request.URL.Parse(link)
+	if err != nil {
+		return fmt.Errorf("node %s value %q is not valid URL: %w", expr, link, err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, linkURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	return s.sendRequest(req)
+}
+
+//TheTwoResponsesShouldBeIdentical checks whether last response and response received before it have the same status code and body
+func (s *Scenario) TheTwoResponsesShouldBeIdentical() error {
+	if s.previousResponse == nil {
+		return errors.New("there is no response received before last response")
+	}
+
+	if s.previousResponse.StatusCode != s.lastResponse.StatusCode {
+		return fmt.Errorf("%w, previous response: %d, last response: %d", ErrResponseCode, s.previousResponse.StatusCode, s.lastResponse.StatusCode)
+	}
+
+	previousBody, err := ioutil.ReadAll(s.previousResponse.Body)
+	if err != nil {
+		return err
+	}
+	s.previousResponse.Body = ioutil.NopCloser(bytes.NewReader(previousBody))
+
+	lastBody := s.GetLastResponseBody()
+	if !bytes.Equal(previousBody, lastBody) {
+		if s.isDebug {
+			s.debugDiff(string(previousBody), string(lastBody))
+		}
+
+		return errors.New("previous and last response bodies are different")
+	}
+
+	return nil
+}
+
+//TheTwoResponsesShouldDiffer checks whether bodies of last response and response received before it are different.
+//JSON bodies are compared semantically, so differences in formatting or order of keys are not taken into account
+func (s *Scenario) TheTwoResponsesShouldDiffer() error {
+	if s.previousResponse == nil {
+		return errors.New("there is no response received before last response")
+	}
+
+	previousBody, err := ioutil.ReadAll(s.previousResponse.Body)
+	if err != nil {
+		return err
+	}
+	s.previousResponse.Body = ioutil.NopCloser(bytes.NewReader(previousBody))
+
+	lastBody := s.GetLastResponseBody()
+
+	var previousJSON, lastJSON interface{}
+	if json.Unmarshal(previousBody, &previousJSON) == nil && json.Unmarshal(lastBody, &lastJSON) == nil {
+		if reflect.DeepEqual(previousJSON, lastJSON) {
+			return errors.New("previous and last response bodies are semantically equal JSON")
+		}
+
+		return nil
+	}
+
+	if bytes.Equal(previousBody, lastBody) {
+		return errors.New("previous and last response bodies are equal")
+	}
+
+	return nil
+}
+
+//TheResponseShouldHaveBeenServedOverHTTPS checks whether last response was served over TLS from https URL
+//URL of final request is checked, so redirects downgrading connection to http are detected
+func (s *Scenario) TheResponseShouldHaveBeenServedOverHTTPS() error {
+	if s.lastResponse.Request == nil || s.lastResponse.Request.URL == nil {
+		return errors.New("last response has no request")
+	}
+
+	if scheme := s.lastResponse.Request.URL.Scheme; scheme != "https" {
+		return fmt.Errorf("last response was served over %s, expected: https", scheme)
+	}
+
+	if s.lastResponse.TLS == nil {
+		return errors.New("last response was not served over TLS connection")
+	}
+
+	return nil
+}
+
+//TheResponseShouldNotHaveRedirectedToDifferentHost checks whether last response was served by host of originally sent request.
+//Redirect chain followed by HTTP client is walked back to first request, so every redirect to other host is detected
+func (s *Scenario) TheResponseShouldNotHaveRedirectedToDifferentHost() error {
+	if s.lastResponse.Request == nil || s.lastResponse.Request.URL == nil {
+		return errors.New("last response has no request")
+	}
+
+	hosts := []string{s.lastResponse.Request.URL.Host}
+	original := s.lastResponse.Request
+	for original.Response != nil && original.Response.Request != nil {
+		original = original.Response.Request
+		hosts = append([]string{original.URL.Host}, hosts...)
+	}
+
+	for _, host := range hosts {
+		if host != original.URL.Host {
+			return fmt.Errorf("request to %s was redirected to different host, redirect chain: %s", original.URL.Host, strings.Join(hosts, " -> "))
+		}
+	}
+
+	return nil
+}
+
+//TheRequestQueryParamShouldBe checks whether query parameter param of last sent request has given value.
+//Request built from step is checked, not request to which it was redirected
+func (s *Scenario) TheRequestQueryParamShouldBe(param, value string) error {
+	if s.lastResponse.Request == nil || s.lastResponse.Request.URL == nil {
+		return errors.New("last response has no request")
+	}
+
+	query := originalRequest(s.lastResponse.Request).URL.Query()
+	values, ok := query[param]
+	if !ok {
+		present := make([]string, 0, len(query))
+		for name := range query {
+			present = append(present, name)
+		}
+		sort.Strings(present)
+
+		return fmt.Errorf("last request has no query param %s, present params: %s", param, strings.Join(present, ", "))
+	}
+
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("last request query param %s has values %q, expected: %q", param, values, value)
+}
+
+//TheResponseShouldHaveBeenServedOverHTTP checks whether last response was served without TLS from http URL
+func (s *Scenario) TheResponseShouldHaveBeenServedOverHTTP() error {
+	if s.lastResponse.Request == nil || s.lastResponse.Request.URL == nil {
+		return errors.New("last response has no request")
+	}
+
+	if scheme := s.lastResponse.Request.URL.Scheme; scheme != "http" || s.lastResponse.TLS != nil {
+		return fmt.Errorf("last response was served over %s, expected: http", scheme)
+	}
+
+	return nil
+}
+
+//TheResponseShouldBeIn checks whether last response body has given data type
+//available data types are listed in switch section
+func (s *Scenario) TheResponseShouldBeIn(dataType string) error {
+	switch dataType {
+	case typeJSON:
+		return s.theResponseShouldBeInJSON()
+	case typeXML:
+		return s.theResponseShouldBeInXML()
+	default:
+		return fmt.Errorf("unknown data type, available values: %s, %s", typeJSON, typeXML)
+	}
+}
+
+//TheResponseBodyShouldNotBeHTML checks whether last response body is not HTML document
+//body is treated as HTML when, after optional BOM and white characters, it starts with <!DOCTYPE html or <html tag
+func (s *Scenario) TheResponseBodyShouldNotBeHTML() error {
+	if isHTML(s.GetLastResponseBody()) {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return errors.New("last response body is HTML document")
+	}
+
+	return nil
+}
+
+//TheDetectedContentTypeShouldBe checks whether content type of last response body detected by http.DetectContentType
+//is equal to expected. When expected has no parameters, only media types are compared, e.g. "text/plain" matches "text/plain; charset=utf-8"
+func (s *Scenario) TheDetectedContentTypeShouldBe(expected string) error {
+	detected := http.DetectContentType(s.GetLastResponseBody())
+	if detected == expected {
+		return nil
+	}
+
+	if !strings.Contains(expected, ";") {
+		detectedMediaType := strings.TrimSpace(strings.Split(detected, ";")[0])
+		if strings.EqualFold(detectedMediaType, strings.TrimSpace(expected)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("detected content type of last response body is %s, expected: %s", detected, expected)
+}
+
+//TheResponseImageDimensionsShouldBe checks whether last response body is PNG, JPEG or GIF image of given width and height
+//image is not fully decoded, only its header is read
+func (s *Scenario) TheResponseImageDimensionsShouldBe(width, height int) error {
+	config, format, err := image.DecodeConfig(bytes.NewReader(s.GetLastResponseBody()))
+	if err != nil {
+		return fmt.Errorf("last response body is not PNG, JPEG or GIF image: %w", err)
+	}
+
+	if config.Width != width || config.Height != height {
+		return fmt.Errorf("last response %s image has dimensions %dx%d, expected: %dx%d", format, config.Width, config.Height, width, height)
+	}
+
+	return nil
+}
+
+//TheResponseBodyShouldContainSubstringTimes checks whether last response body contains exactly count non-overlapping occurrences of substring
+func (s *Scenario) TheResponseBodyShouldContainSubstringTimes(substring string, count int) error {
+	occurrences, err := s.countInLastResponseBody(substring)
+	if err != nil {
+		return err
+	}
+
+	if occurrences != count {
+		return fmt.Errorf("last response body contains %s %d times, expected: %d", substring, occurrences, count)
+	}
+
+	return nil
+}
+
+//TheResponseBodyShouldContainSubstringAtLeastTimes checks whether last response body contains at least count non-overlapping occurrences of substring
+func (s *Scenario) TheResponseBodyShouldContainSubstringAtLeastTimes(substring string, count int) error {
+	occurrences, err := s.countInLastResponseBody(substring)
+	if err != nil {
+		return err
+	}
+
+	if occurrences < count {
+		return fmt.Errorf("last response body contains %s %d times, expected at least: %d", substring, occurrences, count)
+	}
+
+	return nil
+}
+
+//ISaveFromTheLastResponseJSONNodeAs saves from last response json node under given variableName.
+func (s *Scenario) ISaveFromTheLastResponseJSONNodeAs(node, variableName string) error {
+	iVal, err := qjson.Resolve(node, s.GetLastResponseBody())
+
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	s.Save(variableName, iVal)
+
+	return nil
+}
+
+//ISaveRegexCaptureFromResponseBodyAs saves in cache value captured by named group of pattern from last response body.
+//Argument pattern should be regular expression acceptable by regexp package containing group named groupName
+func (s *Scenario) ISaveRegexCaptureFromResponseBodyAs(pattern, groupName, cacheKey string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	groupIndex := re.SubexpIndex(groupName)
+	if groupIndex == -1 {
+		return fmt.Errorf("pattern %s has no group named %s", pattern, groupName)
+	}
+
+	matches := re.FindSubmatch(s.GetLastResponseBody())
+	if matches == nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return fmt.Errorf("pattern %s does not match last response body", pattern)
+	}
+
+	s.Save(cacheKey, string(matches[groupIndex]))
+
+	return nil
+}
+
+//ISaveLastResponseBodyRedactedAs saves in cache copy of last response body, in which nodes from
+//redactExpressionsCSV are replaced with redactedValue. Expressions pointing at not existing nodes are ignored.
+//Redacted body is saved as string, so it may be safely printed or attached to reports.
+func (s *Scenario) ISaveLastResponseBodyRedactedAs(redactExpressionsCSV, cacheKey string) error {
+	var body interface{}
+	if err := json.Unmarshal(s.GetLastResponseBody(), &body); err != nil {
+		return fmt.Errorf("response has %w", ErrJson)
+	}
+
+	for _, expr := range strings.Split(redactExpressionsCSV, ",") {
+		if _, err := setJSONNode(body, strings.TrimSpace(expr), redactedValue); err != nil {
+			return err
+		}
+	}
+
+	redactedBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	s.Save(cacheKey, string(redactedBody))
+
+	return nil
+}
+
+//ISaveCanonicalJSONOfResponseAs saves in cache canonical form of last response body, according to RFC 8785.
+//Canonical form does not depend on order of keys or formatting of numbers, so it may be used to verify signed payloads
+func (s *Scenario) ISaveCanonicalJSONOfResponseAs(cacheKey string) error {
+	canonicalBody, err := canonicalJSON(s.GetLastResponseBody())
+	if err != nil {
+		return fmt.Errorf("response has %w: %s", ErrJson, err)
+	}
+
+	s.Save(cacheKey, string(canonicalBody))
+
+	return nil
+}
+
+//TheCanonicalResponseShouldEqualCached checks whether canonical form of last response body, according to RFC 8785,
+//is equal to canonical JSON saved in cache under cacheKey
+func (s *Scenario) TheCanonicalResponseShouldEqualCached(cacheKey string) error {
+	cachedBody, err := s.GetSavedString(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	canonicalBody, err := canonicalJSON(s.GetLastResponseBody())
+	if err != nil {
+		return fmt.Errorf("response has %w: %s", ErrJson, err)
+	}
+
+	if string(canonicalBody) != cachedBody {
+		if s.isDebug {
+			s.debugDiff(cachedBody, string(canonicalBody))
+		}
+
+		return fmt.Errorf("canonical last response body %s is not equal to cached %s", canonicalBody, cachedBody)
+	}
+
+	return nil
+}
+
+//ILoadEnvironmentConfigFromFile saves in cache every value of environment envName from JSON file,
+//so values like base URL or credentials are available as template values in next steps.
+//File should hold JSON object with environment names as keys, e.g. {"dev": {"HOST": "http://localhost:8080"}}.
+//Only files with .json extension are supported, YAML files are rejected with error
+func (s *Scenario) ILoadEnvironmentConfigFromFile(fileReference, envName string) error {
+	filePath := strings.TrimPrefix(fileReference, "file://")
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".json" {
+		return fmt.Errorf("file %s has unsupported format %s, supported formats: .json", filePath, ext)
+	}
+
+	fileContent, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var environments map[string]map[string]interface{}
+	if err = json.Unmarshal(fileContent, &environments); err != nil {
+		return fmt.Errorf("file %s has %w: %v", filePath, ErrJson, err)
+	}
+
+	environment, ok := environments[envName]
+	if !ok {
+		available := make([]string, 0, len(environments))
+		for name := range environments {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+
+		return fmt.Errorf("unknown environment %s, available environments: %s", envName, strings.Join(available, ", "))
+	}
+
+	for key, value := range environment {
+		s.Save(key, value)
+	}
+
+	return nil
+}
+
+//IGenerateIdempotencyKeyAndSaveItAs generates random UUID and preserve it under given cacheKey,
+//it may be used as idempotency key header in next requests via template value, e.g. {{.KEY}}
+func (s *Scenario) IGenerateIdempotencyKeyAndSaveItAs(cacheKey string) error {
+	key, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	s.Save(cacheKey, key)
+
+	return nil
+}
+
+//IGenerateARandomIntInTheRangeToAndSaveItAs generates random integer from provided range and preserve it under given name in cache
+func (s *Scenario) IGenerateARandomIntInTheRangeToAndSaveItAs(from, to int, name string) error {
+	s.Save(name, randomInt(from, to))
+
+	return nil
+}
+
+//IGenerateARandomFloatInTheRangeToAndSaveItAs generates random float from provided range and preserve it under given name in cache
+func (s *Scenario) IGenerateARandomFloatInTheRangeToAndSaveItAs(from, to int, name string) error {
+	randInt := randomInt(from, to)
+	float01 := rand.Float64()
+
+	strFloat := fmt.Sprintf("%.2f", float01*float64(randInt))
+	floatVal, err := strconv.ParseFloat(strFloat, 64)
+	if err != nil {
+		return err
+	}
+
+	s.Save(name, floatVal)
+
+	return nil
+}
+
+//IGenerateARandomStringOfLengthWithoutUnicodeCharactersAndSaveItAs generates random string of given length without unicode characters
+func (s *Scenario) IGenerateARandomStringOfLengthWithoutUnicodeCharactersAndSaveItAs(strLength int, key string) error {
+	if strLength <= 0 {
+		return fmt.Errorf("provided string length %d can't be less than 1", strLength)
+	}
+
+	s.Save(key, s.stringWithCharset(strLength, charsetLettersOnly))
+
+	return nil
+}
+
+//IGenerateARandomStringOfLengthWithUnicodeCharactersAndSaveItAs generates random string of given length with unicode characters
+func (s *Scenario) IGenerateARandomStringOfLengthWithUnicodeCharactersAndSaveItAs(strLength int, key string) error {
+	if strLength <= 0 {
+		return fmt.Errorf("provided string length %d can't be less than 1", strLength)
+	}
+
+	s.Save(key, s.stringWithCharset(strLength, charsetUnicodeCharacters))
+
+	return nil
+}
+
+//TheJSONResponseShouldHaveKey checks whether last response body contains given key
+func (s *Scenario) TheJSONResponseShouldHaveKey(key string) error {
+	_, err := qjson.Resolve(key, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return fmt.Errorf("%v, missing key '%s'", ErrJsonNode, key)
+	}
+
+	return nil
+}
+
+//TheJSONResponseShouldSatisfy checks whether JSON node from last response body is truthy.
+//Node is falsy if it is null, false, 0, empty string, empty slice or empty map, every other node is truthy.
+//expr should be expression acceptable by qjson package
+func (s *Scenario) TheJSONResponseShouldSatisfy(expr string) error {
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	if !isTruthy(iValue) {
+		return fmt.Errorf("node %s value %v is falsy", expr, iValue)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldNotBe checks whether JSON node from last response body is not of provided type
+//goType may be one of: nil, string, int, float, bool, map, slice
+//node should be expression acceptable by qjson package against JSON node from last response body
+func (s *Scenario) TheJSONNodeShouldNotBe(node string, goType string) error {
+	iNodeVal, err := qjson.Resolve(node, s.GetLastResponseBody())
+	if err != nil {
+		return err
+	}
+
+	isOfType, err := valueIsOfType(iNodeVal, goType)
+	if err != nil {
+		return err
+	}
+
+	if isOfType {
+		return fmt.Errorf("%s value is \"%s\", but expected not to be", node, goType)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldBe checks whether JSON node from last response body is of provided type
+//goType may be one of: nil, string, int, float, bool, map, slice
+//node should be expression acceptable by qjson package against JSON node from last response body
+func (s *Scenario) TheJSONNodeShouldBe(node string, goType string) error {
+	errInvalidType := fmt.Errorf("%s value is not \"%s\", but expected to be", node, goType)
+
+	switch goType {
+	case "nil", "string", "int", "float", "bool", "map", "slice":
+		if err := s.TheJSONNodeShouldNotBe(node, goType); err == nil {
+			return errInvalidType
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%s is unknown type for this step", goType)
+	}
+}
+
+//TheCachedValueShouldBeOfType checks whether value preserved in cache under given cacheKey is of provided type
+//goType may be one of: nil, string, int, float, bool, map, slice
+func (s *Scenario) TheCachedValueShouldBeOfType(cacheKey, goType string) error {
+	iValue, err := s.GetSaved(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	isOfType, err := valueIsOfType(iValue, goType)
+	if err != nil {
+		return err
+	}
+
+	if !isOfType {
+		return fmt.Errorf("cached value under key %s is %T, but expected to be \"%s\"", cacheKey, iValue, goType)
+	}
+
+	return nil
+}
+
+//ICastCachedValueToAs converts value preserved in cache under given cacheKey to provided type and preserve it under newCacheKey
+//goType may be one of: string, int, float, bool
+func (s *Scenario) ICastCachedValueToAs(cacheKey, goType, newCacheKey string) error {
+	iValue, err := s.GetSaved(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	castedValue, err := castValue(iValue, goType)
+	if err != nil {
+		return err
+	}
+
+	s.Save(newCacheKey, castedValue)
+
+	return nil
+}
+
+//IComputeAndSaveAs evaluates arithmetic expression and saves its result as float64 under cacheKey.
+//Expression may contain numbers, operators + - * /, parentheses and cache keys of numeric values, e.g. COUNT * 2 + 1
+func (s *Scenario) IComputeAndSaveAs(expression, cacheKey string) error {
+	result, err := evaluateArithmetic(expression, func(key string) (float64, error) {
+		iValue, err := s.GetSaved(key)
+		if err != nil {
+			return 0, err
+		}
+
+		switch value := iValue.(type) {
+		case int:
+			return float64(value), nil
+		case int64:
+			return float64(value), nil
+		case float64:
+			return value, nil
+		default:
+			return 0, fmt.Errorf("%w: value under key %s is %T, expected number", ErrPreservedData, key, iValue)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("could not compute %s: %w", expression, err)
+	}
+
+	s.Save(cacheKey, result)
+
+	return nil
+}
+
+//ISkipGuardedStepsIfCachedValueIsFalse starts guarded block, in which steps wrapped with Guard are skipped
+//if bool value saved under cacheKey is false. Block lasts until IStopSkippingGuardedSteps or end of scenario
+func (s *Scenario) ISkipGuardedStepsIfCachedValueIsFalse(cacheKey string) error {
+	iValue, err := s.GetSaved(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	condition, ok := iValue.(bool)
+	if !ok {
+		return fmt.Errorf("%w: value under key %s is %T, expected bool", ErrPreservedData, cacheKey, iValue)
+	}
+
+	s.skipGuarded = !condition
+
+	return nil
+}
+
+//IStopSkippingGuardedSteps ends guarded block started by ISkipGuardedStepsIfCachedValueIsFalse
+func (s *Scenario) IStopSkippingGuardedSteps() error {
+	s.skipGuarded = false
+
+	return nil
+}
+
+//junitProperties represents JUnit <properties> element
+type junitProperties struct {
+	XMLName    xml.Name        `xml:"properties"`
+	Properties []junitProperty `xml:"property"`
+}
+
+//junitProperty represents JUnit <property> element
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+//IExportCacheToJUnitProperties writes values saved in cache under comma separated keysCSV to file as JUnit <properties> XML element,
+//so they may be attached to test reports. Only selected keys are exported, so secrets kept in cache do not leak to reports.
+//fileReference should be path to file, optionally prefixed with file://
+func (s *Scenario) IExportCacheToJUnitProperties(keysCSV, fileReference string) error {
+	var keys []string
+	for _, key := range strings.Split(keysCSV, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no cache keys to export were provided")
+	}
+
+	properties := junitProperties{Properties: make([]junitProperty, 0, len(keys))}
+	for _, key := range keys {
+		cachedValue, err := s.GetSaved(key)
+		if err != nil {
+			return fmt.Errorf("%w: no value under key %s", err, key)
+		}
+
+		var value string
+		switch typedValue := cachedValue.(type) {
+		case string:
+			value = typedValue
+		case time.Time:
+			value = typedValue.Format(time.RFC3339Nano)
+		default:
+			valueBytes, err := json.Marshal(typedValue)
+			if err != nil {
+				return fmt.Errorf("value under key %s can't be exported: %w", key, err)
+			}
+
+			value = string(valueBytes)
+		}
+
+		properties.Properties = append(properties.Properties, junitProperty{Name: key, Value: value})
+	}
+
+	content, err := xml.MarshalIndent(properties, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(strings.TrimPrefix(fileReference, "file://"), append([]byte(xml.Header), content...), 0644)
+}
+
+//TheJSONResponseShouldHaveKeys checks whether last request body has keys defined in string separated by comma
+func (s *Scenario) TheJSONResponseShouldHaveKeys(keys string) error {
+	keysSlice := strings.Split(keys, ",")
+
+	errs := make([]error, 0, len(keysSlice))
+	for _, key := range keysSlice {
+		trimmedKey := strings.TrimSpace(key)
+		_, err := qjson.Resolve(trimmedKey, s.GetLastResponseBody())
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("missing key %s", trimmedKey))
+		}
+	}
+
+	if len(errs) > 0 {
+		var errString string
+		for _, err := range errs {
+			errString += fmt.Sprintf("%s\n", err)
+		}
+
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return errors.New(errString)
+	}
+
+	return nil
+}
+
+//TheJSONResponseShouldNotHaveKeys checks whether last request body has none of keys defined in string separated by comma
+func (s *Scenario) TheJSONResponseShouldNotHaveKeys(keys string) error {
+	body := s.GetLastResponseBody()
+	if !json.Valid(body) {
+		return fmt.Errorf("response has %w", ErrJson)
+	}
+
+	keysSlice := strings.Split(keys, ",")
+
+	errs := make([]error, 0, len(keysSlice))
+	for _, key := range keysSlice {
+		trimmedKey := strings.TrimSpace(key)
+		_, err := qjson.Resolve(trimmedKey, body)
+
+		if err == nil {
+			errs = append(errs, fmt.Errorf("unexpected key %s", trimmedKey))
+		}
+	}
+
+	if len(errs) > 0 {
+		var errString string
+		for _, err := range errs {
+			errString += fmt.Sprintf("%s\n", err)
+		}
+
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return errors.New(errString)
+	}
+
+	return nil
+}
+
+//IPrintLastResponseBody prints last response from request
+func (s *Scenario) IPrintLastResponseBody() error {
+	s.printLastResponseBody(os.Stdout)
+
+	return nil
+}
+
+//ISetDebugOutputToFile redirects debug messages to file under given path.
+//File is created if it does not exist, otherwise debug messages are appended to it.
+func (s *Scenario) ISetDebugOutputToFile(filePath string) error {
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	s.SetDebugOutput(f)
+	s.debugFile = f
+
+	return nil
+}
+
+//TheJSONNodeShouldBeSliceOfLength checks whether given key is slice and has given length
+func (s *Scenario) TheJSONNodeShouldBeSliceOfLength(expr string, length int) error {
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	v := reflect.ValueOf(iValue)
+	if v.Kind() == reflect.Slice {
+		if v.Len() != length {
+			return fmt.Errorf("%s slice has length: %d, expected: %d", expr, v.Len(), length)
+		}
+
+		return nil
+	}
+	if s.isDebug {
+		s.debugLastResponseBody()
+	}
+
+	return fmt.Errorf("%s is not slice", expr)
+}
+
+//TheResponseShouldMatchExample checks whether last response body has structure and types of JSON example
+//registered with RegisterResponseExample. Values of nodes may differ from example.
+func (s *Scenario) TheResponseShouldMatchExample(name string) error {
+	example, ok := s.responseExamples[name]
+	if !ok {
+		return fmt.Errorf("response example %s is not registered", name)
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(s.GetLastResponseBody(), &body); err != nil {
+		return fmt.Errorf("response has %w", ErrJson)
+	}
+
+	if err := matchJSONExample(example, body, ""); err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return fmt.Errorf("response does not match example %s: %w", name, err)
+	}
+
+	return nil
+}
+
+//TheJSONNodeSliceShouldBeSortedBy checks whether elements of JSON slice from last response body
+//are sorted by value of subPath node in given direction. Direction may be one of: asc, desc.
+//Values of subPath nodes should be all strings or all numbers.
+func (s *Scenario) TheJSONNodeSliceShouldBeSortedBy(expr, subPath, direction string) error {
+	if direction != "asc" && direction != "desc" {
+		return fmt.Errorf("unknown direction %s, available directions: asc, desc", direction)
+	}
+
+	values, err := s.resolveJSONSliceSubValues(expr, subPath)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(values); i++ {
+		var ordered bool
+		switch previous := values[i-1].(type) {
+		case string:
+			current, ok := values[i].(string)
+			if !ok {
+				return fmt.Errorf("%s[%d].%s is %T, expected string", expr, i, subPath, values[i])
+			}
+
+			ordered = previous <= current
+			if direction == "desc" {
+				ordered = previous >= current
+			}
+		case float64:
+			current, ok := values[i].(float64)
+			if !ok {
+				return fmt.Errorf("%s[%d].%s is %T, expected number", expr, i, subPath, values[i])
+			}
+
+			ordered = previous <= current
+			if direction == "desc" {
+				ordered = previous >= current
+			}
+		default:
+			return fmt.Errorf("%s[%d].%s is %T, expected string or number", expr, i-1, subPath, values[i-1])
+		}
+
+		if !ordered {
+			return fmt.Errorf("%s is not sorted %s by %s: %v at index %d is followed by %v", expr, direction, subPath, values[i-1], i-1, values[i])
+		}
+	}
+
+	return nil
+}
+
+//TheJSONNodeSliceDatesShouldBeAscending checks whether dates from subPath node of elements of JSON slice
+//from last response body are in chronological order. Dates are parsed with layouts set by SetDateLayouts
+func (s *Scenario) TheJSONNodeSliceDatesShouldBeAscending(expr, subPath string) error {
+	values, err := s.resolveJSONSliceSubValues(expr, subPath)
+	if err != nil {
+		return err
+	}
+
+	var previous time.Time
+	for i, value := range values {
+		dateString, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: %s[%d].%s is %T, expected string", ErrJsonNode, expr, i, subPath, value)
+		}
+
+		date, err := parseDate(dateString, s.dateLayouts)
+		if err != nil {
+			return fmt.Errorf("%s[%d].%s: %w", expr, i, subPath, err)
+		}
+
+		if i > 0 && date.Before(previous) {
+			return fmt.Errorf("%s is not in chronological order by %s: %s at index %d is followed by %s", expr, subPath, values[i-1], i-1, dateString)
+		}
+
+		previous = date
+	}
+
+	return nil
+}
+
+//TheJSONNodeSliceElementsShouldHaveUnique checks whether value of subPath node is unique
+//across all elements of JSON slice from last response body
+func (s *Scenario) TheJSONNodeSliceElementsShouldHaveUnique(expr, subPath string) error {
+	values, err := s.resolveJSONSliceSubValues(expr, subPath)
+	if err != nil {
+		return err
+	}
+
+	occurrences := make(map[string]int, len(values))
+	var duplicates []string
+	for _, value := range values {
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		key := string(valueBytes)
+		occurrences[key]++
+		if occurrences[key] == 2 {
+			duplicates = append(duplicates, key)
+		}
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%s elements have duplicated %s values: %s", expr, subPath, strings.Join(duplicates, ", "))
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldBeOfValue compares json node value from expression to expected by user dataValue of given by user dataType
+//available data types are listed in switch section in each case directive
+func (s *Scenario) TheJSONNodeShouldBeOfValue(expr, dataType, dataValue string) error {
+	nodeValueReplaced, err := s.replaceTemplatedValue(dataValue)
+	if err != nil {
+		return err
+	}
+
+	if s.isDebug {
+		s.debugf("Replaced value: %s\n", nodeValueReplaced)
+	}
+
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	switch dataType {
+	case "string":
+		strVal, ok := iValue.(string)
+		if !ok {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
+		}
+
+		if strVal != nodeValueReplaced {
+			if s.isDebug {
+				s.debugLastResponseBody()
+				s.debugDiff(nodeValueReplaced, strVal)
+			}
+			return fmt.Errorf("node %s string value: %s is not equal to expected string value: %s", expr, strVal, nodeValueReplaced)
+		}
+	case "int":
+		floatVal, ok := iValue.(float64)
+		if !ok {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
+		}
+
+		intVal := int(floatVal)
+
+		intNodeValue, err := strconv.Atoi(nodeValueReplaced)
+
+		if err != nil {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("replaced node %s value %s could not be converted to int", expr, nodeValueReplaced)
+		}
+
+		if intVal != intNodeValue {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("node %s int value: %d is not equal to expected int value: %d", expr, intVal, intNodeValue)
+		}
+	case "float":
+		floatVal, ok := iValue.(float64)
+		if !ok {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
+		}
+
+		floatNodeValue, err := strconv.ParseFloat(nodeValueReplaced, 64)
+		if err != nil {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("replaced node %s value %s could not be converted to float64", expr, nodeValueReplaced)
+		}
+
+		if floatVal != floatNodeValue {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("node %s float value %f is not equal to expected float value %f", expr, floatVal, floatNodeValue)
+		}
+	case "bool":
+		boolVal, ok := iValue.(bool)
+		if !ok {
+			if s.isDebug {
+				s.debugLastResponseBody()
 			}
 			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
 		}
 
-		if strVal != nodeValueReplaced {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("node %s string value: %s is not equal to expected string value: %s", expr, strVal, nodeValueReplaced)
+		boolNodeValue, err := strconv.ParseBool(nodeValueReplaced)
+		if err != nil {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("replaced node %s value %s could not be converted to bool", expr, nodeValueReplaced)
+		}
+
+		if boolVal != boolNodeValue {
+			if s.isDebug {
+				s.debugLastResponseBody()
+			}
+			return fmt.Errorf("node %s bool value %t is not equal to expected bool value %t", expr, boolVal, boolNodeValue)
+		}
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldBeOfValueGreaterThan checks whether numeric JSON node from last response body is greater than value
+func (s *Scenario) TheJSONNodeShouldBeOfValueGreaterThan(expr string, value float64) error {
+	nodeValue, err := s.resolveJSONNumber(expr)
+	if err != nil {
+		return err
+	}
+
+	if nodeValue <= value {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return fmt.Errorf("node %s has value %v, expected greater than: %v", expr, nodeValue, value)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldBeOfValueLessThan checks whether numeric JSON node from last response body is less than value
+func (s *Scenario) TheJSONNodeShouldBeOfValueLessThan(expr string, value float64) error {
+	nodeValue, err := s.resolveJSONNumber(expr)
+	if err != nil {
+		return err
+	}
+
+	if nodeValue >= value {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return fmt.Errorf("node %s has value %v, expected less than: %v", expr, nodeValue, value)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldEqualNode checks whether two JSON nodes from last response body have equal values of the same type
+//exprA and exprB should be expressions acceptable by qjson package
+func (s *Scenario) TheJSONNodeShouldEqualNode(exprA, exprB string) error {
+	body := s.GetLastResponseBody()
+	iValueA, err := qjson.Resolve(exprA, body)
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
 		}
-	case "int":
-		floatVal, ok := iValue.(float64)
-		if !ok {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
+
+		return err
+	}
+
+	iValueB, err := qjson.Resolve(exprB, body)
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	if !reflect.DeepEqual(iValueA, iValueB) {
+		return fmt.Errorf("node %s value %v (%T) is not equal to node %s value %v (%T)", exprA, iValueA, iValueA, exprB, iValueB, iValueB)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldEqualSumOfNodes checks whether numeric JSON node from last response body is equal to sum of other numeric nodes
+//addendExprsCSV should be comma separated expressions acceptable by qjson package, values are compared with floatEpsilon tolerance
+func (s *Scenario) TheJSONNodeShouldEqualSumOfNodes(targetExpr, addendExprsCSV string) error {
+	target, err := s.resolveJSONNumber(targetExpr)
+	if err != nil {
+		return err
+	}
+
+	var sum float64
+	for _, expr := range strings.Split(addendExprsCSV, ",") {
+		addend, err := s.resolveJSONNumber(strings.TrimSpace(expr))
+		if err != nil {
+			return err
+		}
+
+		sum += addend
+	}
+
+	if math.Abs(target-sum) > floatEpsilon {
+		return fmt.Errorf("node %s value %v is not equal to sum of nodes %s: %v", targetExpr, target, addendExprsCSV, sum)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldBeOneOfValues checks whether JSON node from last response body of given dataType is equal to one of values
+//valuesCSV should be comma separated values, may include template values. dataType may be one of: string, int, float, bool
+func (s *Scenario) TheJSONNodeShouldBeOneOfValues(expr, dataType, valuesCSV string) error {
+	valuesReplaced, err := s.replaceTemplatedValue(valuesCSV)
+	if err != nil {
+		return err
+	}
+
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	var hasDataType bool
+	switch dataType {
+	case "string":
+		_, hasDataType = iValue.(string)
+	case "int", "float":
+		_, hasDataType = iValue.(float64)
+	case "bool":
+		_, hasDataType = iValue.(bool)
+	default:
+		return fmt.Errorf("%s is unknown type for this step", dataType)
+	}
+
+	if !hasDataType {
+		return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
+	}
+
+	nodeValue, err := castValue(iValue, dataType)
+	if err != nil {
+		return fmt.Errorf("node %s: %w", expr, err)
+	}
+
+	for _, value := range strings.Split(valuesReplaced, ",") {
+		candidate, err := castValue(strings.TrimSpace(value), dataType)
+		if err != nil {
+			return err
+		}
+
+		if candidate == nodeValue {
+			return nil
+		}
+	}
+
+	if s.isDebug {
+		s.debugLastResponseBody()
+	}
+
+	return fmt.Errorf("node %s value %v is not one of: %s", expr, nodeValue, valuesReplaced)
+}
+
+//TheJSONNodeShouldBeOfValueFromFile checks whether JSON node from last response body is equal to node fileExpr from file.
+//Only JSON files are supported, file is recognised by .json extension. fileReference may be prefixed with file://
+func (s *Scenario) TheJSONNodeShouldBeOfValueFromFile(expr, fileReference, fileExpr string) error {
+	filePath := strings.TrimPrefix(fileReference, "file://")
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".json" {
+		return fmt.Errorf("file %s has unsupported format %s, supported formats: .json", filePath, ext)
+	}
+
+	fileContent, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	iExpected, err := qjson.Resolve(fileExpr, fileContent)
+	if err != nil {
+		return fmt.Errorf("could not resolve node %s in file %s: %w", fileExpr, filePath, err)
+	}
+
+	iNodeValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	if !reflect.DeepEqual(iNodeValue, iExpected) {
+		return fmt.Errorf("node %s value %v (%T) is not equal to node %s from file %s: %v (%T)", expr, iNodeValue, iNodeValue, fileExpr, filePath, iExpected, iExpected)
+	}
+
+	return nil
+}
+
+//TheResponseBodyShouldEqualFile checks whether raw bytes of last response body are equal to content of file.
+//fileReference should be path to file, optionally prefixed with file://
+func (s *Scenario) TheResponseBodyShouldEqualFile(fileReference string) error {
+	filePath := strings.TrimPrefix(fileReference, "file://")
+	expected, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	body := s.GetLastResponseBody()
+	if bytes.Equal(body, expected) {
+		return nil
+	}
+
+	offset := 0
+	for offset < len(body) && offset < len(expected) && body[offset] == expected[offset] {
+		offset++
+	}
+
+	if len(body) != len(expected) && (offset == len(body) || offset == len(expected)) {
+		return fmt.Errorf("response body has %d bytes, file %s has %d bytes, contents differ from byte offset %d", len(body), filePath, len(expected), offset)
+	}
+
+	return fmt.Errorf("response body differs from file %s at byte offset %d: got 0x%02x, expected 0x%02x", filePath, offset, body[offset], expected[offset])
+}
+
+//fileSignatures holds magic numbers starting files of given type, file of given type may start with any of them
+var fileSignatures = map[string][][]byte{
+	"pdf":  {[]byte("%PDF-")},
+	"zip":  {[]byte("PK\x03\x04"), []byte("PK\x05\x06"), []byte("PK\x07\x08")},
+	"png":  {[]byte("\x89PNG\r\n\x1a\n")},
+	"gzip": {[]byte("\x1f\x8b")},
+}
+
+//TheResponseBodyShouldBeFileType checks whether last response body starts with magic number of given file type.
+//fileType may be one of: pdf, zip, png, gzip
+func (s *Scenario) TheResponseBodyShouldBeFileType(fileType string) error {
+	signatures, ok := fileSignatures[strings.ToLower(fileType)]
+	if !ok {
+		return fmt.Errorf("unknown file type %s, available file types: gzip, pdf, png, zip", fileType)
+	}
+
+	body := s.GetLastResponseBody()
+	for _, signature := range signatures {
+		if bytes.HasPrefix(body, signature) {
+			return nil
+		}
+	}
+
+	prefix := body
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+
+	return fmt.Errorf("response body is not %s file, it starts with bytes % x", fileType, prefix)
+}
+
+//TheResponseCompressionRatioShouldBeAtLeast checks whether ratio of decompressed to compressed size of last response body
+//is at least ratio. Request should set "Accept-Encoding" header explicitly, otherwise HTTP client decompresses body
+//transparently and compressed size is unknown. Supported encodings: gzip
+func (s *Scenario) TheResponseCompressionRatioShouldBeAtLeast(ratio float64) error {
+	if s.lastResponse.Uncompressed {
+		return errors.New("last response body was decompressed by HTTP client, request should set Accept-Encoding header explicitly")
+	}
+
+	encoding := s.lastResponse.Header.Get("Content-Encoding")
+	if encoding != "gzip" {
+		return fmt.Errorf("last response body has Content-Encoding %q, expected: gzip", encoding)
+	}
+
+	body := s.GetLastResponseBody()
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not decompress last response body: %w", err)
+	}
+	defer reader.Close()
+
+	decompressedSize, err := io.Copy(ioutil.Discard, reader)
+	if err != nil {
+		return fmt.Errorf("could not decompress last response body: %w", err)
+	}
+
+	actualRatio := float64(decompressedSize) / float64(len(body))
+	if actualRatio < ratio {
+		return fmt.Errorf("last response body compression ratio is %.2f (%d to %d bytes), expected at least %.2f", actualRatio, decompressedSize, len(body), ratio)
+	}
+
+	return nil
+}
+
+//TheJSONNodeStringShouldHaveLength checks whether string JSON node from last response body has given length
+//length is counted in runes, not bytes
+func (s *Scenario) TheJSONNodeStringShouldHaveLength(expr string, length int) error {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	if runeCount := utf8.RuneCountInString(strVal); runeCount != length {
+		return fmt.Errorf("node %s string value has length: %d, expected: %d", expr, runeCount, length)
+	}
+
+	return nil
+}
+
+//TheJSONNodeStringShouldHaveLengthBetween checks whether string JSON node from last response body has length between min and max, inclusive
+//length is counted in runes, not bytes
+func (s *Scenario) TheJSONNodeStringShouldHaveLengthBetween(expr string, min, max int) error {
+	if min > max {
+		return fmt.Errorf("min length %d is greater than max length %d", min, max)
+	}
+
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	if runeCount := utf8.RuneCountInString(strVal); runeCount < min || runeCount > max {
+		return fmt.Errorf("node %s string value has length: %d, expected between %d and %d", expr, runeCount, min, max)
+	}
+
+	return nil
+}
+
+//TheJSONNodeURLDecodedShouldBe checks whether percent-decoded string JSON node from last response body
+//is equal to templated expectedTemplate. Only percent-encoded sequences are decoded, + is not replaced with space
+func (s *Scenario) TheJSONNodeURLDecodedShouldBe(expr, expectedTemplate string) error {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := url.PathUnescape(strVal)
+	if err != nil {
+		return fmt.Errorf("node %s value %q is not valid percent-encoded string: %w", expr, strVal, err)
+	}
+
+	expected, err := s.replaceTemplatedValue(expectedTemplate)
+	if err != nil {
+		return err
+	}
+
+	if decoded != expected {
+		return fmt.Errorf("node %s URL decoded value is %q, expected: %q", expr, decoded, expected)
+	}
+
+	return nil
+}
+
+//TheJSONNodeBase64DecodedShouldBe checks whether base64 decoded string JSON node from last response body
+//is equal to templated expectedTemplate
+func (s *Scenario) TheJSONNodeBase64DecodedShouldBe(expr, expectedTemplate string) error {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := decodeBase64(strVal)
+	if err != nil {
+		return fmt.Errorf("node %s value %q is not valid base64 string: %w", expr, strVal, err)
+	}
+
+	expected, err := s.replaceTemplatedValue(expectedTemplate)
+	if err != nil {
+		return err
+	}
+
+	if string(decoded) != expected {
+		return fmt.Errorf("node %s base64 decoded value is %q, expected: %q", expr, decoded, expected)
+	}
+
+	return nil
+}
+
+//TheJSONNodeShouldBeValidURL checks whether string JSON node from last response body is absolute URL with scheme and host
+func (s *Scenario) TheJSONNodeShouldBeValidURL(expr string) error {
+	_, err := s.resolveJSONURL(expr)
+
+	return err
+}
+
+//TheJSONNodeShouldBeValidURLAndShouldBeReachable checks whether string JSON node from last response body is absolute URL
+//and HEAD request sent to it returns 2xx status code. Response to HEAD request does not replace last response.
+//Request is sent with settings of scenario, e.g. Basic Auth or request timeout
+func (s *Scenario) TheJSONNodeShouldBeValidURLAndShouldBeReachable(expr string) error {
+	nodeURL, err := s.resolveJSONURL(expr)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodHead, nodeURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("URL %s from node %s is not reachable: %w", nodeURL, expr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("URL %s from node %s is not reachable, HEAD request returned status code %d", nodeURL, expr, resp.StatusCode)
+	}
+
+	return nil
+}
+
+//TheJSONNodeBase64DecodedShouldHaveFormat checks whether base64 decoded string JSON node from last response body
+//has given data format. dataFormat may be one of: JSON, XML
+func (s *Scenario) TheJSONNodeBase64DecodedShouldHaveFormat(expr, dataFormat string) error {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := decodeBase64(strVal)
+	if err != nil {
+		return fmt.Errorf("node %s value %q is not valid base64 string: %w", expr, strVal, err)
+	}
+
+	switch dataFormat {
+	case typeJSON:
+		if !json.Valid(decoded) {
+			return fmt.Errorf("node %s base64 decoded value has %w", expr, ErrJson)
+		}
+	case typeXML:
+		if !isXML(decoded) {
+			return fmt.Errorf("node %s base64 decoded value is not XML", expr)
+		}
+	default:
+		return fmt.Errorf("unknown data format %s, available values: %s, %s", dataFormat, typeJSON, typeXML)
+	}
+
+	return nil
+}
+
+//ISaveJSONNodeBase64DecodedAs saves in cache under cacheKey base64 decoded string JSON node from last response body.
+//Decoded value is saved as string
+func (s *Scenario) ISaveJSONNodeBase64DecodedAs(expr, cacheKey string) error {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := decodeBase64(strVal)
+	if err != nil {
+		return fmt.Errorf("node %s value %q is not valid base64 string: %w", expr, strVal, err)
+	}
+
+	s.Save(cacheKey, string(decoded))
+
+	return nil
+}
+
+//TheJSONNodeShouldEqualCachedValue checks whether JSON node from last response body is equal to value preserved in cache under cacheKey
+//when types differ, cached value is converted to type of node before comparison, e.g. cached int 10 is equal to node 10.0
+func (s *Scenario) TheJSONNodeShouldEqualCachedValue(expr, cacheKey string) error {
+	iCachedValue, err := s.GetSaved(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	iNodeValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return err
+	}
+
+	if reflect.DeepEqual(iNodeValue, iCachedValue) {
+		return nil
+	}
+
+	var nodeType string
+	switch iNodeValue.(type) {
+	case string:
+		nodeType = "string"
+	case float64:
+		nodeType = "float"
+	case bool:
+		nodeType = "bool"
+	}
+
+	if nodeType != "" {
+		if converted, err := castValue(iCachedValue, nodeType); err == nil && converted == iNodeValue {
+			return nil
 		}
+	}
 
-		intVal := int(floatVal)
+	return fmt.Errorf("node %s value %v (%T) is not equal to cached value %s: %v (%T)", expr, iNodeValue, iNodeValue, cacheKey, iCachedValue, iCachedValue)
+}
 
-		intNodeValue, err := strconv.Atoi(nodeValueReplaced)
+//TheJSONNodeShouldEqualHashOfCachedValue checks whether string JSON node from last response body is hex encoded hash
+//of value saved under cacheKey. Cached value should be string or []byte. algorithm may be one of: md5, sha1, sha256
+func (s *Scenario) TheJSONNodeShouldEqualHashOfCachedValue(expr, algorithm, cacheKey string) error {
+	iCachedValue, err := s.GetSaved(cacheKey)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("replaced node %s value %s could not be converted to int", expr, nodeValueReplaced)
-		}
+	var data []byte
+	switch cachedValue := iCachedValue.(type) {
+	case string:
+		data = []byte(cachedValue)
+	case []byte:
+		data = cachedValue
+	default:
+		return fmt.Errorf("%w: value under key %s is %T, expected string or []byte", ErrPreservedData, cacheKey, iCachedValue)
+	}
 
-		if intVal != intNodeValue {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("node %s int value: %d is not equal to expected int value: %d", expr, intVal, intNodeValue)
-		}
-	case "float":
-		floatVal, ok := iValue.(float64)
-		if !ok {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
-		}
+	expected, err := hexHash(algorithm, data)
+	if err != nil {
+		return err
+	}
 
-		floatNodeValue, err := strconv.ParseFloat(nodeValueReplaced, 64)
-		if err != nil {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("replaced node %s value %s could not be converted to float64", expr, nodeValueReplaced)
-		}
+	nodeValue, err := s.resolveJSONString(expr)
+	if err != nil {
+		return err
+	}
 
-		if floatVal != floatNodeValue {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("node %s float value %f is not equal to expected float value %f", expr, floatVal, floatNodeValue)
-		}
-	case "bool":
-		boolVal, ok := iValue.(bool)
-		if !ok {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("expected %s to be %s, got %v", expr, dataType, iValue)
-		}
+	if !strings.EqualFold(nodeValue, expected) {
+		return fmt.Errorf("node %s value %s is not %s hash of cached value %s: %s", expr, nodeValue, algorithm, cacheKey, expected)
+	}
 
-		boolNodeValue, err := strconv.ParseBool(nodeValueReplaced)
-		if err != nil {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("replaced node %s value %s could not be converted to bool", expr, nodeValueReplaced)
-		}
+	return nil
+}
 
-		if boolVal != boolNodeValue {
-			if s.isDebug {
-				_ = s.IPrintLastResponseBody()
-			}
-			return fmt.Errorf("node %s bool value %t is not equal to expected bool value %t", expr, boolVal, boolNodeValue)
-		}
+//TheJSONNodeDateShouldBeBetween checks whether JSON node from last response body is date between from and to, inclusive.
+//Node value, from and to should be dates in one of scenario date layouts, RFC3339 by default. Arguments from and to may include template values.
+func (s *Scenario) TheJSONNodeDateShouldBeBetween(expr, from, to string) error {
+	nodeDate, err := s.resolveJSONNodeDate(expr)
+	if err != nil {
+		return err
+	}
+
+	fromDate, err := s.parseTemplatedDate(from)
+	if err != nil {
+		return err
+	}
+
+	toDate, err := s.parseTemplatedDate(to)
+	if err != nil {
+		return err
+	}
+
+	if nodeDate.Before(fromDate) || nodeDate.After(toDate) {
+		return fmt.Errorf("node %s date %s is not between %s and %s", expr,
+			nodeDate.Format(time.RFC3339Nano), fromDate.Format(time.RFC3339Nano), toDate.Format(time.RFC3339Nano))
 	}
 
 	return nil
 }
 
-//IWait waits for given timeInterval amount of time
+//TheJSONNodeDateShouldBeWithinOfNow checks whether JSON node from last response body is date
+//which differs from current time by no more than given timeInterval.
 //timeInterval should be string valid for time.ParseDuration func
+func (s *Scenario) TheJSONNodeDateShouldBeWithinOfNow(expr, timeInterval string) error {
+	duration, err := time.ParseDuration(timeInterval)
+	if err != nil {
+		return err
+	}
+
+	nodeDate, err := s.resolveJSONNodeDate(expr)
+	if err != nil {
+		return err
+	}
+
+	diff := s.now().Sub(nodeDate)
+	if diff < 0 {
+		diff = -diff
+	}
+
+	if diff > duration {
+		return fmt.Errorf("node %s date %s differs from now by %s, expected at most %s", expr, nodeDate.Format(time.RFC3339Nano), diff, duration)
+	}
+
+	return nil
+}
+
+//IWait waits for given timeInterval amount of time
+//timeInterval should be string valid for time.ParseDuration func, jitter set by ISetWaitJitter is applied to it
 func (s *Scenario) IWait(timeInterval string) error {
 	duration, err := time.ParseDuration(timeInterval)
 	if err != nil {
 		return err
 	}
-	time.Sleep(duration)
+	time.Sleep(s.withJitter(duration))
+	return nil
+}
+
+//IStartFlowTimer preserves current time in cache as start of flow measured by TheFlowShouldHaveTakenLessThan
+func (s *Scenario) IStartFlowTimer() error {
+	s.Save(flowStartCacheKey, s.now())
+
+	return nil
+}
+
+//TheFlowShouldHaveTakenLessThan checks whether time elapsed since IStartFlowTimer is less than timeInterval
+//timeInterval should be string valid for time.ParseDuration func
+func (s *Scenario) TheFlowShouldHaveTakenLessThan(timeInterval string) error {
+	duration, err := time.ParseDuration(timeInterval)
+	if err != nil {
+		return err
+	}
+
+	start, err := s.GetSavedTime(flowStartCacheKey)
+	if err != nil {
+		return fmt.Errorf("flow timer was not started: %w", err)
+	}
+
+	if elapsed := s.now().Sub(start); elapsed >= duration {
+		return fmt.Errorf("flow took %s, expected less than %s", elapsed, duration)
+	}
+
+	return nil
+}
+
+//ISetWaitJitter sets random jitter applied to IWait durations and delays between retries of requests.
+//Duration d becomes d + d * j, where j is random number from range [-percent/100, percent/100].
+//Argument percent should be in range from 0 to 100, 0 disables jitter
+func (s *Scenario) ISetWaitJitter(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("jitter percent %d should be in range from 0 to 100", percent)
+	}
+
+	s.waitJitterPercent = percent
+
+	return nil
+}
+
+//ISetMaxResponseBodySize sets maximal size in bytes of response bodies read in current scenario.
+//Sending request fails when response body is larger. Argument bytes equal to 0 disables limit
+func (s *Scenario) ISetMaxResponseBodySize(bytes int64) error {
+	if bytes < 0 {
+		return fmt.Errorf("max response body size %d should not be negative", bytes)
+	}
+
+	s.maxResponseBodySize = bytes
+
+	return nil
+}
+
+//ISetRequestTimeout sets maximal duration of sending each next request in current scenario and reading its response.
+//Request exceeding timeout fails. Argument timeout should be compatible with time.ParseDuration, 0 disables timeout
+func (s *Scenario) ISetRequestTimeout(timeout string) error {
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return err
+	}
+
+	if duration < 0 {
+		return fmt.Errorf("request timeout %s should not be negative", duration)
+	}
+
+	s.requestTimeout = duration
+
 	return nil
 }
 
@@ -491,7 +2265,7 @@ func (s *Scenario) TheResponseShouldHaveHeader(name string) error {
 	}
 
 	if s.isDebug {
-		fmt.Printf("last HTTP response headers: %+v", headers)
+		s.debugf("last HTTP response headers: %+v\n", headers)
 	}
 
 	return fmt.Errorf("could not find header %s in last HTTP response", name)
@@ -512,8 +2286,231 @@ func (s *Scenario) TheResponseShouldHaveHeaderOfValue(name, value string) error
 	}
 
 	if s.isDebug {
-		fmt.Printf("last HTTP response headers: %+v", headers)
+		s.debugf("last HTTP response headers: %+v\n", headers)
 	}
 
 	return fmt.Errorf("could not find header %s in last HTTP response", name)
 }
+
+//TheResponseShouldHaveHeaderValues checks whether all values of repeated header of last HTTP response,
+//e.g. Set-Cookie, are equal to values from doc string, one value per line. Values are compared as sets,
+//so their order does not matter. Values may contain commas, e.g. cookie expiration dates
+func (s *Scenario) TheResponseShouldHaveHeaderValues(name string, values *godog.DocString) error {
+	actualValues := s.lastResponse.Header.Values(name)
+	missing, unexpected := setDifferences(strings.Split(values.Content, "\n"), actualValues, false)
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("header %s has values %q, missing values: %q, unexpected values: %q", name, actualValues, missing, unexpected)
+}
+
+//TheResponseHeaderShouldBeSet checks whether comma separated members of header of last HTTP response,
+//e.g. Allow or Access-Control-Allow-Methods, are equal to comma separated valuesCSV.
+//Members are compared as sets, case-insensitively, so their order and letter case do not matter
+func (s *Scenario) TheResponseHeaderShouldBeSet(name, valuesCSV string) error {
+	actualValues := s.lastResponse.Header.Values(name)
+	if len(actualValues) == 0 {
+		return fmt.Errorf("could not find header %s in last HTTP response", name)
+	}
+
+	var members []string
+	for _, value := range actualValues {
+		members = append(members, strings.Split(value, ",")...)
+	}
+
+	missing, unexpected := setDifferences(strings.Split(valuesCSV, ","), members, true)
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("header %s has value %q, missing members: %q, unexpected members: %q", name, strings.Join(actualValues, ", "), missing, unexpected)
+}
+
+//TheResponseCookieShouldBeSecure checks whether cookie set by last HTTP response has Secure attribute
+func (s *Scenario) TheResponseCookieShouldBeSecure(name string) error {
+	cookie, err := s.responseCookie(name)
+	if err != nil {
+		return err
+	}
+
+	if !cookie.Secure {
+		return fmt.Errorf("cookie %s is not Secure, Set-Cookie: %s", name, cookie.Raw)
+	}
+
+	return nil
+}
+
+//TheResponseCookieShouldBeHttpOnly checks whether cookie set by last HTTP response has HttpOnly attribute
+func (s *Scenario) TheResponseCookieShouldBeHttpOnly(name string) error {
+	cookie, err := s.responseCookie(name)
+	if err != nil {
+		return err
+	}
+
+	if !cookie.HttpOnly {
+		return fmt.Errorf("cookie %s is not HttpOnly, Set-Cookie: %s", name, cookie.Raw)
+	}
+
+	return nil
+}
+
+//TheResponseCookieShouldBeSameSite checks whether cookie set by last HTTP response has SameSite attribute of given mode.
+//mode may be one of: Strict, Lax, None
+func (s *Scenario) TheResponseCookieShouldBeSameSite(name, mode string) error {
+	cookie, err := s.responseCookie(name)
+	if err != nil {
+		return err
+	}
+
+	if actual := sameSiteName(cookie.SameSite); !strings.EqualFold(actual, mode) {
+		return fmt.Errorf("cookie %s has SameSite %q, expected: %q, Set-Cookie: %s", name, actual, mode, cookie.Raw)
+	}
+
+	return nil
+}
+
+//TheResponseUpstreamHitCountHeaderShouldBe checks whether header of last HTTP response counting upstream hits,
+//e.g. set by proxy collapsing identical requests, is integer equal to count
+func (s *Scenario) TheResponseUpstreamHitCountHeaderShouldBe(headerName string, count int) error {
+	header := s.lastResponse.Header.Get(headerName)
+	if header == "" {
+		return fmt.Errorf("could not find header %s in last HTTP response", headerName)
+	}
+
+	hitCount, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil {
+		return fmt.Errorf("header %s value %q is not integer", headerName, header)
+	}
+
+	if hitCount != count {
+		return fmt.Errorf("header %s has upstream hit count %d, expected: %d", headerName, hitCount, count)
+	}
+
+	return nil
+}
+
+//TheResponseShouldHaveNumericHeaderOfValue checks whether header of last HTTP response is number equal to value
+func (s *Scenario) TheResponseShouldHaveNumericHeaderOfValue(name string, value float64) error {
+	headerValue, err := s.numericHeader(name)
+	if err != nil {
+		return err
+	}
+
+	if math.Abs(headerValue-value) > floatEpsilon {
+		return fmt.Errorf("header %s has value %v, expected: %v", name, headerValue, value)
+	}
+
+	return nil
+}
+
+//TheResponseShouldHaveNumericHeaderGreaterThan checks whether header of last HTTP response is number greater than value
+func (s *Scenario) TheResponseShouldHaveNumericHeaderGreaterThan(name string, value float64) error {
+	headerValue, err := s.numericHeader(name)
+	if err != nil {
+		return err
+	}
+
+	if headerValue <= value {
+		return fmt.Errorf("header %s has value %v, expected greater than: %v", name, headerValue, value)
+	}
+
+	return nil
+}
+
+//TheResponseShouldHaveNumericHeaderLessThan checks whether header of last HTTP response is number less than value
+func (s *Scenario) TheResponseShouldHaveNumericHeaderLessThan(name string, value float64) error {
+	headerValue, err := s.numericHeader(name)
+	if err != nil {
+		return err
+	}
+
+	if headerValue >= value {
+		return fmt.Errorf("header %s has value %v, expected less than: %v", name, headerValue, value)
+	}
+
+	return nil
+}
+
+//TheResponseContentLengthShouldMatchBody checks whether Content-Length declared by last HTTP response
+//is equal to number of bytes of its body, mismatch means that body was truncated or padded
+func (s *Scenario) TheResponseContentLengthShouldMatchBody() error {
+	if s.lastResponse.ContentLength < 0 {
+		return errors.New("last HTTP response has unknown Content-Length")
+	}
+
+	if bodyLength := int64(len(s.GetLastResponseBody())); bodyLength != s.lastResponse.ContentLength {
+		return fmt.Errorf("last HTTP response has Content-Length %d, but body has %d bytes", s.lastResponse.ContentLength, bodyLength)
+	}
+
+	return nil
+}
+
+//TheResponseVaryHeaderShouldContain checks whether Vary header of last HTTP response lists headerName.
+//Header names are compared case-insensitively
+func (s *Scenario) TheResponseVaryHeaderShouldContain(headerName string) error {
+	var varyHeaders []string
+	for _, value := range s.lastResponse.Header.Values("Vary") {
+		for _, varyHeader := range strings.Split(value, ",") {
+			if varyHeader = strings.TrimSpace(varyHeader); varyHeader != "" {
+				varyHeaders = append(varyHeaders, varyHeader)
+			}
+		}
+	}
+
+	for _, varyHeader := range varyHeaders {
+		if strings.EqualFold(varyHeader, headerName) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("last HTTP response Vary header does not contain %s, Vary: %q", headerName, strings.Join(varyHeaders, ", "))
+}
+
+//ISaveFromTheLastResponseHeaderAs saves value of header of last HTTP response in cache under cacheKey,
+//so it may be used as template value in next steps, e.g. Location of created resource
+func (s *Scenario) ISaveFromTheLastResponseHeaderAs(name, cacheKey string) error {
+	headerValue := s.lastResponse.Header.Get(name)
+	if headerValue == "" {
+		if s.isDebug {
+			s.debugf("last HTTP response headers: %+v\n", s.lastResponse.Header)
+		}
+
+		return fmt.Errorf("could not find header %s in last HTTP response", name)
+	}
+
+	s.Save(cacheKey, headerValue)
+
+	return nil
+}
+
+//ISaveETagAs saves ETag header of last HTTP response under cacheKey
+func (s *Scenario) ISaveETagAs(cacheKey string) error {
+	etag := s.lastResponse.Header.Get("ETag")
+	if etag == "" {
+		return errors.New("last HTTP response has no ETag header")
+	}
+
+	s.Save(cacheKey, etag)
+
+	return nil
+}
+
+//TheETagShouldEqualCached checks whether ETag header of last HTTP response is equal to ETag saved under cacheKey
+func (s *Scenario) TheETagShouldEqualCached(cacheKey string) error {
+	cachedETag, err := s.GetSavedString(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	etag := s.lastResponse.Header.Get("ETag")
+	if etag == "" {
+		return errors.New("last HTTP response has no ETag header")
+	}
+
+	if etag != cachedETag {
+		return fmt.Errorf("last HTTP response ETag %s is not equal to cached ETag %s", etag, cachedETag)
+	}
+
+	return nil
+}
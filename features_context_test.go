@@ -1,8 +1,32 @@
 package gdutils
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
 )
 
 func TestApiFeature_theJSONNodeShouldBeOfValue(t *testing.T) {
@@ -144,7 +168,7 @@ func TestApiFeature_theJSONNodeShouldBeOfValue(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			af := &Scenario{
 				cache:        tt.fields.saved,
-				lastResponse: tt.fields.lastResponse,
+				lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.fields.lastResponseBody))},
 			}
 			if err := af.TheJSONNodeShouldBeOfValue(tt.args.expr, tt.args.dataType, tt.args.dataValue); (err != nil) != tt.wantErr {
 				t.Errorf("TheJSONNodeShouldBeOfValue() error = %v, wantErr %v", err, tt.wantErr)
@@ -230,7 +254,7 @@ func TestApiFeature_TheJSONNodeShouldBeSliceOfLength(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			af := &Scenario{
 				cache:        tt.fields.saved,
-				lastResponse: tt.fields.lastResponse,
+				lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.fields.lastResponseBody))},
 			}
 			if err := af.TheJSONNodeShouldBeSliceOfLength(tt.args.expr, tt.args.length); (err != nil) != tt.wantErr {
 				t.Errorf("TheJSONNodeShouldBeSliceOfLength() error = %v, wantErr %v", err, tt.wantErr)
@@ -278,11 +302,11 @@ func TestApiFeature_TheResponseShouldBeInXML(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			af := &Scenario{
 				cache:        tt.fields.saved,
-				lastResponse: tt.fields.lastResponse,
+				lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.fields.lastResponseBody))},
 				isDebug:      tt.fields.isDebug,
 			}
-			if err := af.TheResponseShouldBeInXML(); (err != nil) != tt.wantErr {
-				t.Errorf("TheResponseShouldBeInXML() error = %v, wantErr %v", err, tt.wantErr)
+			if err := af.TheResponseShouldBeIn(typeXML); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseShouldBeIn() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -440,7 +464,7 @@ func TestApiFeature_TheJSONNodeShouldNotBe(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			af := &Scenario{
 				cache:        tt.fields.saved,
-				lastResponse: tt.fields.lastResponse,
+				lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.fields.lastResponseBody))},
 				isDebug:      tt.fields.isDebug,
 			}
 			if err := af.TheJSONNodeShouldNotBe(tt.args.node, tt.args.goType); (err != nil) != tt.wantErr {
@@ -602,7 +626,7 @@ func TestApiFeature_TheJSONNodeShouldBe(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			af := &Scenario{
 				cache:        tt.fields.saved,
-				lastResponse: tt.fields.lastResponse,
+				lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.fields.lastResponseBody))},
 				isDebug:      tt.fields.isDebug,
 			}
 			if err := af.TheJSONNodeShouldBe(tt.args.node, tt.args.goType); (err != nil) != tt.wantErr {
@@ -611,3 +635,3645 @@ func TestApiFeature_TheJSONNodeShouldBe(t *testing.T) {
 		})
 	}
 }
+
+func Test_detectContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{name: "json object", body: []byte(`{"name": "ivo"}`), want: "application/json"},
+		{name: "json array", body: []byte(`[1, 2]`), want: "application/json"},
+		{name: "json string", body: []byte(`"abc"`), want: "application/json"},
+		{name: "xml", body: []byte(`<?xml version="1.0"?><data><id>1</id></data>`), want: "application/xml"},
+		{name: "plain text", body: []byte(`abc`), want: "text/plain; charset=utf-8"},
+		{name: "unclosed xml", body: []byte(`<data>`), want: "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectContentType(tt.body); got != tt.want {
+				t.Errorf("detectContentType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ConcurrentScenariosHaveIsolatedLastResponse(t *testing.T) {
+	names := []string{"first", "second"}
+	scenarios := make([]*Scenario, len(names))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+	for i, name := range names {
+		srv := httptest.NewServer(http.HandlerFunc(func(name string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprintf(w, `{"name": "%s"}`, name)
+			}
+		}(name)))
+		defer srv.Close()
+
+		scenarios[i] = &Scenario{}
+		scenarios[i].ResetScenario(false)
+
+		wg.Add(1)
+		go func(s *Scenario, url string) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, url, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(scenarios[i], srv.URL)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	for i, name := range names {
+		if err := scenarios[i].TheJSONNodeShouldBeOfValue("name", "string", name); err != nil {
+			t.Errorf("scenario %s does not see its own last response: %v", name, err)
+		}
+	}
+}
+
+func TestApiFeature_TheCachedValueShouldBeOfType(t *testing.T) {
+	type args struct {
+		cacheKey string
+		goType   string
+	}
+	tests := []struct {
+		name    string
+		cache   map[string]interface{}
+		args    args
+		wantErr bool
+	}{
+		{name: "missing key", cache: map[string]interface{}{}, args: args{cacheKey: "user", goType: "string"}, wantErr: true},
+		{name: "is string", cache: map[string]interface{}{"user": "abc"}, args: args{cacheKey: "user", goType: "string"}, wantErr: false},
+		{name: "is not string", cache: map[string]interface{}{"user": 1.5}, args: args{cacheKey: "user", goType: "string"}, wantErr: true},
+		{name: "is int", cache: map[string]interface{}{"user": 2}, args: args{cacheKey: "user", goType: "int"}, wantErr: false},
+		{name: "is int <- float without fraction", cache: map[string]interface{}{"user": 2.0}, args: args{cacheKey: "user", goType: "int"}, wantErr: false},
+		{name: "is float", cache: map[string]interface{}{"user": 2.5}, args: args{cacheKey: "user", goType: "float"}, wantErr: false},
+		{name: "is not float", cache: map[string]interface{}{"user": 2}, args: args{cacheKey: "user", goType: "float"}, wantErr: true},
+		{name: "is nil", cache: map[string]interface{}{"user": nil}, args: args{cacheKey: "user", goType: "nil"}, wantErr: false},
+		{name: "is map", cache: map[string]interface{}{"user": map[string]interface{}{}}, args: args{cacheKey: "user", goType: "map"}, wantErr: false},
+		{name: "is slice", cache: map[string]interface{}{"user": []interface{}{}}, args: args{cacheKey: "user", goType: "slice"}, wantErr: false},
+		{name: "unknown type", cache: map[string]interface{}{"user": "abc"}, args: args{cacheKey: "user", goType: "xxx"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{cache: tt.cache}
+			if err := s.TheCachedValueShouldBeOfType(tt.args.cacheKey, tt.args.goType); (err != nil) != tt.wantErr {
+				t.Errorf("TheCachedValueShouldBeOfType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_castValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		goType  string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "float to int", value: 10.0, goType: "int", want: 10},
+		{name: "float with fraction to int", value: 10.5, goType: "int", wantErr: true},
+		{name: "string to int", value: "12", goType: "int", want: 12},
+		{name: "invalid string to int", value: "abc", goType: "int", wantErr: true},
+		{name: "bool to int", value: true, goType: "int", wantErr: true},
+		{name: "int to float", value: 3, goType: "float", want: 3.0},
+		{name: "string to float", value: "1.5", goType: "float", want: 1.5},
+		{name: "float to string", value: 1.5, goType: "string", want: "1.5"},
+		{name: "int to string", value: 7, goType: "string", want: "7"},
+		{name: "bool to string", value: false, goType: "string", want: "false"},
+		{name: "string to bool", value: "true", goType: "bool", want: true},
+		{name: "float to bool", value: 1.0, goType: "bool", wantErr: true},
+		{name: "nil to string", value: nil, goType: "string", wantErr: true},
+		{name: "unknown type", value: "abc", goType: "map", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := castValue(tt.value, tt.goType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("castValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("castValue() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ICastCachedValueToAs(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("COUNT", 10.0)
+
+	if err := s.ICastCachedValueToAs("COUNT", "int", "COUNT"); err != nil {
+		t.Fatalf("ICastCachedValueToAs() error = %v", err)
+	}
+
+	if got, err := s.GetSaved("COUNT"); err != nil || got != 10 {
+		t.Errorf("cached value under key COUNT = %v (%T), want 10 (int)", got, got)
+	}
+
+	if err := s.ICastCachedValueToAs("COUNT", "string", "COUNT_STR"); err != nil {
+		t.Fatalf("ICastCachedValueToAs() error = %v", err)
+	}
+
+	if got, err := s.GetSavedString("COUNT_STR"); err != nil || got != "10" {
+		t.Errorf("cached value under key COUNT_STR = %q, want \"10\"", got)
+	}
+
+	if err := s.ICastCachedValueToAs("COUNT", "map", "COUNT_MAP"); err == nil {
+		t.Errorf("ICastCachedValueToAs() expected error for unknown type")
+	}
+
+	if err := s.ICastCachedValueToAs("MISSING", "int", "MISSING"); err == nil {
+		t.Errorf("ICastCachedValueToAs() expected error for missing cache key")
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeadersAndDeadlineFrom(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		deadline interface{}
+		wantErr  string
+	}{
+		{name: "deadline in future", deadline: time.Now().Add(time.Minute), wantErr: ""},
+		{name: "deadline in past", deadline: time.Now().Add(-time.Minute), wantErr: "request timed out"},
+		{name: "deadline is not time", deadline: "tomorrow", wantErr: "expected time.Time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("DEADLINE", tt.deadline)
+
+			err := s.ISendRequestToWithBodyAndHeadersAndDeadlineFrom(http.MethodGet, srv.URL, "DEADLINE", &godog.DocString{Content: `{"body": {}, "headers": {}}`})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ISendRequestToWithBodyAndHeadersAndDeadlineFrom() unexpected error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ISendRequestToWithBodyAndHeadersAndDeadlineFrom() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISetDebugOutputToFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "debug.log")
+
+	s := &Scenario{}
+	s.ResetScenario(true)
+	if err := s.ISetDebugOutputToFile(filePath); err != nil {
+		t.Fatalf("ISetDebugOutputToFile() error = %v", err)
+	}
+
+	if err := s.TheResponseShouldHaveHeader("X-Missing"); err == nil {
+		t.Fatalf("TheResponseShouldHaveHeader() expected error")
+	}
+	s.SetDebugOutput(os.Stdout)
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("could not read debug file: %v", err)
+	}
+
+	if !strings.Contains(string(content), "last HTTP response headers") {
+		t.Errorf("debug file content = %q, expected debug message about headers", content)
+	}
+}
+
+func TestApiFeature_ISetStructuredDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name": "ivo"}`))
+	}))
+	defer srv.Close()
+
+	var output bytes.Buffer
+	s := &Scenario{}
+	s.ResetScenario(true)
+	s.SetDebugOutput(&output)
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	if err := s.ISetStructuredDebug("true"); err != nil {
+		t.Fatalf("ISetStructuredDebug() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 debug lines, got %d: %q", len(lines), output.String())
+	}
+
+	var entries [2]debugEntry
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatalf("debug line %q is not valid JSON: %v", line, err)
+		}
+
+		if entries[i].Time != now.Format(time.RFC3339Nano) || entries[i].Level != debugLevel {
+			t.Errorf("debug line %q has no scenario clock time or level", line)
+		}
+	}
+
+	if _, ok := entries[0].Fields["curl"]; !ok {
+		t.Errorf("request debug line has no curl field: %q", lines[0])
+	}
+
+	body, ok := entries[1].Fields["body"].(map[string]interface{})
+	if !ok || body["name"] != "ivo" {
+		t.Errorf("response debug line has no structured body: %q", lines[1])
+	}
+}
+
+func Test_lineDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     []string
+	}{
+		{name: "equal", expected: "a\nb", actual: "a\nb", want: []string{" a", " b"}},
+		{name: "changed line", expected: "a\nb\nc", actual: "a\nx\nc", want: []string{" a", "-b", "+x", " c"}},
+		{name: "added line", expected: "a", actual: "a\nb", want: []string{" a", "+b"}},
+		{name: "removed line", expected: "a\nb", actual: "b", want: []string{"-a", " b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lineDiff(tt.expected, tt.actual)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("lineDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_LastDebugMessages(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(true)
+	if s.LastDebugMessages() != nil {
+		t.Fatalf("LastDebugMessages() expected nil for default debug output")
+	}
+
+	s.SetDebugOutput(&RecordingDebugger{})
+	_ = s.TheResponseShouldHaveHeader("X-Missing")
+	_ = s.TheResponseShouldHaveHeaderOfValue("X-Missing", "abc")
+
+	messages := s.LastDebugMessages()
+	if len(messages) != 2 {
+		t.Fatalf("LastDebugMessages() returned %d messages, want 2: %q", len(messages), messages)
+	}
+
+	for _, msg := range messages {
+		if !strings.HasPrefix(msg, "last HTTP response headers") {
+			t.Errorf("unexpected debug message %q", msg)
+		}
+	}
+}
+
+func TestApiFeature_TheJSONNodeDateShouldBeBetween(t *testing.T) {
+	type args struct {
+		expr string
+		from string
+		to   string
+	}
+	tests := []struct {
+		name    string
+		body    string
+		args    args
+		wantErr bool
+	}{
+		{name: "date in range", body: `{"createdAt": "2021-05-10T10:00:00Z"}`,
+			args: args{expr: "createdAt", from: "2021-05-01T00:00:00Z", to: "2021-06-01T00:00:00Z"}, wantErr: false},
+		{name: "date equal to range boundary", body: `{"createdAt": "2021-05-01T02:00:00+02:00"}`,
+			args: args{expr: "createdAt", from: "2021-05-01T00:00:00Z", to: "2021-06-01T00:00:00Z"}, wantErr: false},
+		{name: "date before range", body: `{"createdAt": "2021-04-30T23:59:59Z"}`,
+			args: args{expr: "createdAt", from: "2021-05-01T00:00:00Z", to: "2021-06-01T00:00:00Z"}, wantErr: true},
+		{name: "date after range", body: `{"createdAt": "2021-06-01T00:00:01Z"}`,
+			args: args{expr: "createdAt", from: "2021-05-01T00:00:00Z", to: "2021-06-01T00:00:00Z"}, wantErr: true},
+		{name: "node is not string", body: `{"createdAt": 1620640800}`,
+			args: args{expr: "createdAt", from: "2021-05-01T00:00:00Z", to: "2021-06-01T00:00:00Z"}, wantErr: true},
+		{name: "node is not date", body: `{"createdAt": "yesterday"}`,
+			args: args{expr: "createdAt", from: "2021-05-01T00:00:00Z", to: "2021-06-01T00:00:00Z"}, wantErr: true},
+		{name: "invalid range boundary", body: `{"createdAt": "2021-05-10T10:00:00Z"}`,
+			args: args{expr: "createdAt", from: "May 2021", to: "2021-06-01T00:00:00Z"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			if err := s.TheJSONNodeDateShouldBeBetween(tt.args.expr, tt.args.from, tt.args.to); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeDateShouldBeBetween() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeDateShouldBeWithinOfNow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		body         string
+		layouts      []string
+		timeInterval string
+		wantErr      bool
+	}{
+		{name: "recent date", body: fmt.Sprintf(`{"createdAt": "%s"}`, now.Add(-time.Second).Format(time.RFC3339)),
+			timeInterval: "1m", wantErr: false},
+		{name: "date in near future", body: fmt.Sprintf(`{"createdAt": "%s"}`, now.Add(30*time.Second).Format(time.RFC3339)),
+			timeInterval: "1m", wantErr: false},
+		{name: "old date", body: fmt.Sprintf(`{"createdAt": "%s"}`, now.Add(-time.Hour).Format(time.RFC3339)),
+			timeInterval: "1m", wantErr: true},
+		{name: "invalid time interval", body: fmt.Sprintf(`{"createdAt": "%s"}`, now.Format(time.RFC3339)),
+			timeInterval: "abc", wantErr: true},
+		{name: "date in custom layout", body: fmt.Sprintf(`{"createdAt": "%s"}`, now.UTC().Format("2006-01-02 15:04:05")),
+			layouts: []string{time.RFC3339, "2006-01-02 15:04:05"}, timeInterval: "1m", wantErr: false},
+		{name: "date in not accepted layout", body: fmt.Sprintf(`{"createdAt": "%s"}`, now.UTC().Format("2006-01-02 15:04:05")),
+			timeInterval: "1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			if len(tt.layouts) > 0 {
+				s.SetDateLayouts(tt.layouts...)
+			}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			if err := s.TheJSONNodeDateShouldBeWithinOfNow("createdAt", tt.timeInterval); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeDateShouldBeWithinOfNow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_AvailableSteps(t *testing.T) {
+	steps := (&Scenario{}).AvailableSteps()
+	if len(steps) != len(stepDescriptions) {
+		t.Errorf("AvailableSteps() returned %d steps, but %d are registered", len(steps), len(stepDescriptions))
+	}
+
+	for _, step := range steps {
+		if step.Description == "" {
+			t.Errorf("step %s has no description in stepDescriptions registry", step.Name)
+		}
+
+		if nonStepMethods[step.Name] {
+			t.Errorf("Go API method %s is returned as step", step.Name)
+		}
+
+		if step.Name == "TheResponseStatusCodeShouldBe" && (len(step.ArgTypes) != 1 || step.ArgTypes[0] != "int") {
+			t.Errorf("step %s has arg types %v, want [int]", step.Name, step.ArgTypes)
+		}
+	}
+}
+
+func Test_isHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want bool
+	}{
+		{name: "doctype", body: []byte(`<!DOCTYPE html><html><body>error</body></html>`), want: true},
+		{name: "html tag", body: []byte(`<html lang="en"></html>`), want: true},
+		{name: "leading white characters", body: []byte("\n\t  <!doctype html>"), want: true},
+		{name: "leading BOM", body: []byte("\xef\xbb\xbf<HTML>"), want: true},
+		{name: "json", body: []byte(`{"html": "<html>"}`), want: false},
+		{name: "xml", body: []byte(`<?xml version="1.0"?><data/>`), want: false},
+		{name: "empty", body: []byte(``), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isHTML(tt.body); got != tt.want {
+				t.Errorf("isHTML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseBodyShouldNotBeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "html error page", body: "<!DOCTYPE html><html><body>502 Bad Gateway</body></html>", wantErr: true},
+		{name: "json body", body: `{"html": "<html>"}`},
+		{name: "empty body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(tt.body))}}
+			if err := s.TheResponseBodyShouldNotBeHTML(); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseBodyShouldNotBeHTML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheDetectedContentTypeShouldBe(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "exact match", body: `abc`, expected: "text/plain; charset=utf-8", wantErr: false},
+		{name: "media type match", body: `abc`, expected: "text/plain", wantErr: false},
+		{name: "png", body: "\x89PNG\x0D\x0A\x1A\x0A", expected: "image/png", wantErr: false},
+		{name: "mismatch", body: `abc`, expected: "application/json", wantErr: true},
+		{name: "parameters mismatch", body: `abc`, expected: "text/plain; charset=latin1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			if err := s.TheDetectedContentTypeShouldBe(tt.expected); (err != nil) != tt.wantErr {
+				t.Errorf("TheDetectedContentTypeShouldBe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseImageDimensionsShouldBe(t *testing.T) {
+	var pngImage bytes.Buffer
+	if err := png.Encode(&pngImage, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("could not encode png: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    []byte
+		width   int
+		height  int
+		wantErr bool
+	}{
+		{name: "matching dimensions", body: pngImage.Bytes(), width: 3, height: 2, wantErr: false},
+		{name: "different dimensions", body: pngImage.Bytes(), width: 2, height: 3, wantErr: true},
+		{name: "not an image", body: []byte(`{"width": 3}`), width: 3, height: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.body))}
+			if err := s.TheResponseImageDimensionsShouldBe(tt.width, tt.height); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseImageDimensionsShouldBe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISaveRegexCaptureFromResponseBodyAs(t *testing.T) {
+	body := `<form><input type="hidden" name="csrf" value="a1b2c3"></form>`
+	tests := []struct {
+		name      string
+		pattern   string
+		groupName string
+		want      string
+		wantErr   bool
+	}{
+		{name: "captured group", pattern: `name="csrf" value="(?P<token>[^"]+)"`, groupName: "token", want: "a1b2c3", wantErr: false},
+		{name: "missing group", pattern: `name="csrf" value="(?P<token>[^"]+)"`, groupName: "other", wantErr: true},
+		{name: "no match", pattern: `name="session" value="(?P<token>[^"]+)"`, groupName: "token", wantErr: true},
+		{name: "invalid pattern", pattern: `(?P<token>[`, groupName: "token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			err := s.ISaveRegexCaptureFromResponseBodyAs(tt.pattern, tt.groupName, "TOKEN")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ISaveRegexCaptureFromResponseBodyAs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got, _ := s.GetSavedString("TOKEN"); got != tt.want {
+				t.Errorf("saved value = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_replaceTemplatedValue(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		filePath := filepath.Join(dir, name)
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("could not write file %s: %v", filePath, err)
+		}
+
+		return filePath
+	}
+
+	userFile := writeFile("user.json", `{"name": "{{.NAME}}"}`)
+	wrapperFile := writeFile("wrapper.json", fmt.Sprintf(`{"user": {{ include "file://%s" }}}`, userFile))
+	recursiveFile := filepath.Join(dir, "recursive.json")
+	writeFile("recursive.json", fmt.Sprintf(`{{ include "file://%s" }}`, recursiveFile))
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "cached value", input: `{{.NAME}}`, want: "ivo"},
+		{name: "include", input: fmt.Sprintf(`{{ include "file://%s" }}`, userFile), want: `{"name": "ivo"}`},
+		{name: "nested include", input: fmt.Sprintf(`{{ include "%s" }}`, wrapperFile), want: `{"user": {"name": "ivo"}}`},
+		{name: "recursive include", input: fmt.Sprintf(`{{ include "file://%s" }}`, recursiveFile), wantErr: true},
+		{name: "missing file", input: `{{ include "file:///not/existing/file.json" }}`, wantErr: true},
+		{name: "invalid template", input: `{{ .NAME `, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("NAME", "ivo")
+
+			got, err := s.replaceTemplatedValue(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replaceTemplatedValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("replaceTemplatedValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldEqualNode(t *testing.T) {
+	body := `{
+	"total": 10,
+	"sum": 10,
+	"count": "10",
+	"user": {"name": "ivo", "tags": ["a"]},
+	"author": {"name": "ivo", "tags": ["a"]},
+	"editor": {"name": "ivo", "tags": ["b"]}
+}`
+	tests := []struct {
+		name    string
+		exprA   string
+		exprB   string
+		wantErr bool
+	}{
+		{name: "equal numbers", exprA: "total", exprB: "sum", wantErr: false},
+		{name: "same value different types", exprA: "total", exprB: "count", wantErr: true},
+		{name: "equal objects", exprA: "user", exprB: "author", wantErr: false},
+		{name: "different objects", exprA: "user", exprB: "editor", wantErr: true},
+		{name: "missing node", exprA: "total", exprB: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldEqualNode(tt.exprA, tt.exprB); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldEqualNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldEqualSumOfNodes(t *testing.T) {
+	body := `{
+	"total": 0.3,
+	"items": [{"price": 0.1}, {"price": 0.2}],
+	"name": "cart"
+}`
+	tests := []struct {
+		name       string
+		targetExpr string
+		addends    string
+		wantErr    bool
+	}{
+		{name: "sum equal within epsilon", targetExpr: "total", addends: "items[0].price, items[1].price", wantErr: false},
+		{name: "sum not equal", targetExpr: "total", addends: "items[0].price", wantErr: true},
+		{name: "addend is not number", targetExpr: "total", addends: "items[0].price,name", wantErr: true},
+		{name: "target is not number", targetExpr: "name", addends: "items[0].price", wantErr: true},
+		{name: "missing addend", targetExpr: "total", addends: "items[2].price", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldEqualSumOfNodes(tt.targetExpr, tt.addends); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldEqualSumOfNodes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeStringShouldHaveLength(t *testing.T) {
+	body := `{"ascii": "abc", "unicode": "🤡🤖🧟", "number": 3}`
+	tests := []struct {
+		name    string
+		expr    string
+		length  int
+		wantErr bool
+	}{
+		{name: "ascii string", expr: "ascii", length: 3, wantErr: false},
+		{name: "multibyte string counted in runes", expr: "unicode", length: 3, wantErr: false},
+		{name: "different length", expr: "ascii", length: 4, wantErr: true},
+		{name: "not string", expr: "number", length: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeStringShouldHaveLength(tt.expr, tt.length); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeStringShouldHaveLength() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldEqualCachedValue(t *testing.T) {
+	body := `{"id": 10, "name": "ivo", "active": true, "tags": ["a", "b"]}`
+	tests := []struct {
+		name    string
+		expr    string
+		cached  interface{}
+		wantErr bool
+	}{
+		{name: "float equal to int", expr: "id", cached: 10, wantErr: false},
+		{name: "float equal to float", expr: "id", cached: 10.0, wantErr: false},
+		{name: "float equal to numeric string", expr: "id", cached: "10", wantErr: false},
+		{name: "float not equal", expr: "id", cached: 11, wantErr: true},
+		{name: "string equal", expr: "name", cached: "ivo", wantErr: false},
+		{name: "string not equal", expr: "name", cached: "pawel", wantErr: true},
+		{name: "bool equal to string", expr: "active", cached: "true", wantErr: false},
+		{name: "slice equal", expr: "tags", cached: []interface{}{"a", "b"}, wantErr: false},
+		{name: "slice not equal", expr: "tags", cached: []interface{}{"b", "a"}, wantErr: true},
+		{name: "missing node", expr: "missing", cached: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("CACHED", tt.cached)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldEqualCachedValue(tt.expr, "CACHED"); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldEqualCachedValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+//countingRoundTripper is http.RoundTripper middleware counting sent requests
+type countingRoundTripper struct {
+	next  http.RoundTripper
+	count int
+}
+
+func (c *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.count++
+	return c.next.RoundTrip(req)
+}
+
+func TestApiFeature_SetRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	rt := &countingRoundTripper{next: DefaultTransport()}
+	s := &Scenario{}
+	s.SetRoundTripper(rt)
+	s.ResetScenario(false)
+
+	for i := 0; i < 2; i++ {
+		if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	if rt.count != 2 {
+		t.Errorf("round tripper was used %d times, want 2", rt.count)
+	}
+}
+
+//mockMetricsObserver is MetricsObserver recording received callbacks
+type mockMetricsObserver struct {
+	started    []string
+	statusCode int
+	bodyBytes  int
+	duration   time.Duration
+}
+
+func (m *mockMetricsObserver) RequestStarted(req *http.Request) {
+	m.started = append(m.started, req.Method)
+}
+
+func (m *mockMetricsObserver) ResponseReceived(req *http.Request, statusCode int, duration time.Duration, bodyBytes int) {
+	m.statusCode = statusCode
+	m.duration = duration
+	m.bodyBytes = bodyBytes
+}
+
+func TestApiFeature_SetMetricsObserver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	defer srv.Close()
+
+	obs := &mockMetricsObserver{}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.SetMetricsObserver(obs)
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if len(obs.started) != 1 || obs.started[0] != http.MethodPost {
+		t.Errorf("RequestStarted() calls = %v, want [POST]", obs.started)
+	}
+
+	if obs.statusCode != http.StatusCreated || obs.bodyBytes != len(`{"id": 1}`) || obs.duration <= 0 {
+		t.Errorf("ResponseReceived() got status %d, bytes %d, duration %s", obs.statusCode, obs.bodyBytes, obs.duration)
+	}
+}
+
+func TestApiFeature_TheResponseShouldHaveBeenServedOverHTTPS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tlsSrv := httptest.NewTLSServer(handler)
+	defer tlsSrv.Close()
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		wantHTTPSErr bool
+		wantHTTPErr  bool
+	}{
+		{name: "https server", url: tlsSrv.URL, wantHTTPSErr: false, wantHTTPErr: true},
+		{name: "http server", url: srv.URL, wantHTTPSErr: true, wantHTTPErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, tt.url, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+				t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+			}
+
+			if err := s.TheResponseShouldHaveBeenServedOverHTTPS(); (err != nil) != tt.wantHTTPSErr {
+				t.Errorf("TheResponseShouldHaveBeenServedOverHTTPS() error = %v, wantErr %v", err, tt.wantHTTPSErr)
+			}
+
+			if err := s.TheResponseShouldHaveBeenServedOverHTTP(); (err != nil) != tt.wantHTTPErr {
+				t.Errorf("TheResponseShouldHaveBeenServedOverHTTP() error = %v, wantErr %v", err, tt.wantHTTPErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeadersWithBackoff(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	closedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedSrv.Close()
+
+	body := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISendRequestToWithBodyAndHeadersWithBackoff(http.MethodGet, srv.URL, 3, "1ms", body); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeadersWithBackoff() error = %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("HTTP error status should not be retried, server was called %d times", calls)
+	}
+
+	err := s.ISendRequestToWithBodyAndHeadersWithBackoff(http.MethodGet, closedSrv.URL, 3, "1ms", body)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("ISendRequestToWithBodyAndHeadersWithBackoff() error = %v, want error after 3 attempts", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeadersWithBackoff(http.MethodGet, srv.URL, 0, "1ms", body); err == nil {
+		t.Errorf("ISendRequestToWithBodyAndHeadersWithBackoff() expected error for 0 attempts")
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeadersWithBackoff_doesNotRetryNonNetworkErrors(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"name": "too long body"}`))
+	}))
+	defer srv.Close()
+
+	body := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISetMaxResponseBodySize(5); err != nil {
+		t.Fatalf("ISetMaxResponseBodySize() error = %v", err)
+	}
+
+	err := s.ISendRequestToWithBodyAndHeadersWithBackoff(http.MethodGet, srv.URL, 3, "1ms", body)
+	if err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("ISendRequestToWithBodyAndHeadersWithBackoff() error = %v, want body size limit error", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("body size limit error should not be retried, server was called %d times", calls)
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeadersWithBackoff_doesNotRetryUnsupportedScheme(t *testing.T) {
+	var debugOutput bytes.Buffer
+	body := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(true)
+	s.SetDebugOutput(&debugOutput)
+
+	err := s.ISendRequestToWithBodyAndHeadersWithBackoff(http.MethodGet, "ftp://example.com/users", 3, "1ms", body)
+	if err == nil || !strings.Contains(err.Error(), "unsupported protocol scheme") || strings.Contains(err.Error(), "attempts") {
+		t.Errorf("ISendRequestToWithBodyAndHeadersWithBackoff() error = %v, want unsupported protocol scheme error after one attempt", err)
+	}
+
+	if strings.Contains(debugOutput.String(), "attempt 1 of 3 failed") {
+		t.Errorf("unsupported protocol scheme should not be retried, debug output: %s", debugOutput.String())
+	}
+}
+
+func TestApiFeature_withJitter(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.SetRandSource(rand.NewSource(1))
+
+	if got := s.withJitter(time.Second); got != time.Second {
+		t.Errorf("withJitter() without jitter = %s, want %s", got, time.Second)
+	}
+
+	if err := s.ISetWaitJitter(101); err == nil {
+		t.Errorf("ISetWaitJitter() expected error for percent above 100")
+	}
+
+	if err := s.ISetWaitJitter(10); err != nil {
+		t.Fatalf("ISetWaitJitter() error = %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if got := s.withJitter(time.Second); got < 900*time.Millisecond || got > 1100*time.Millisecond {
+			t.Fatalf("withJitter() = %s, want value within 10%% of %s", got, time.Second)
+		}
+	}
+
+	s.SetRandSource(rand.NewSource(1))
+	first := s.withJitter(time.Second)
+	s.SetRandSource(rand.NewSource(1))
+	if second := s.withJitter(time.Second); first != second {
+		t.Errorf("withJitter() with the same random source = %s and %s, want equal", first, second)
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldBeOfValueFromFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "expected.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"user": {"name": "ivo", "roles": ["admin"]}}`), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	body := `{"data": {"name": "ivo", "roles": ["admin"], "age": 30}}`
+	tests := []struct {
+		name          string
+		expr          string
+		fileReference string
+		fileExpr      string
+		wantErr       bool
+	}{
+		{name: "equal string", expr: "data.name", fileReference: "file://" + jsonFile, fileExpr: "user.name", wantErr: false},
+		{name: "equal slice", expr: "data.roles", fileReference: jsonFile, fileExpr: "user.roles", wantErr: false},
+		{name: "not equal", expr: "data.age", fileReference: jsonFile, fileExpr: "user.name", wantErr: true},
+		{name: "missing file node", expr: "data.name", fileReference: jsonFile, fileExpr: "user.email", wantErr: true},
+		{name: "unsupported format", expr: "data.name", fileReference: filepath.Join(dir, "expected.yaml"), fileExpr: "user.name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldBeOfValueFromFile(tt.expr, tt.fileReference, tt.fileExpr); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldBeOfValueFromFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheTwoResponsesShouldBeIdentical(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/counter" {
+			_, _ = fmt.Fprintf(w, `{"call": %d}`, calls)
+			return
+		}
+
+		_, _ = fmt.Fprintf(w, `{"key": "%s"}`, r.Header.Get("Idempotency-Key"))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.TheTwoResponsesShouldBeIdentical(); err == nil {
+		t.Errorf("TheTwoResponsesShouldBeIdentical() expected error without responses")
+	}
+
+	if err := s.IGenerateIdempotencyKeyAndSaveItAs("KEY"); err != nil {
+		t.Fatalf("IGenerateIdempotencyKeyAndSaveItAs() error = %v", err)
+	}
+
+	body := &godog.DocString{Content: `{"body": {}, "headers": {"Idempotency-Key": "{{.KEY}}"}}`}
+	if err := s.ISendRequestToWithBodyAndHeadersTwice(http.MethodPost, srv.URL, body); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeadersTwice() error = %v", err)
+	}
+
+	if err := s.TheTwoResponsesShouldBeIdentical(); err != nil {
+		t.Errorf("TheTwoResponsesShouldBeIdentical() error = %v", err)
+	}
+
+	key, _ := s.GetSavedString("KEY")
+	if err := s.TheJSONNodeShouldBeOfValue("key", "string", key); err != nil {
+		t.Errorf("idempotency key was not sent: %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeadersTwice(http.MethodPost, srv.URL+"/counter", body); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeadersTwice() error = %v", err)
+	}
+
+	if err := s.TheTwoResponsesShouldBeIdentical(); err == nil {
+		t.Errorf("TheTwoResponsesShouldBeIdentical() expected error for different bodies")
+	}
+}
+
+func Test_newUUID(t *testing.T) {
+	uuidRegexp := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, err := newUUID()
+	if err != nil {
+		t.Fatalf("newUUID() error = %v", err)
+	}
+
+	second, _ := newUUID()
+	if !uuidRegexp.MatchString(first) || first == second {
+		t.Errorf("newUUID() = %s, %s, want two different version 4 UUIDs", first, second)
+	}
+}
+
+func TestApiFeature_TheFlowShouldHaveTakenLessThan(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.TheFlowShouldHaveTakenLessThan("1m"); err == nil {
+		t.Errorf("TheFlowShouldHaveTakenLessThan() expected error when timer was not started")
+	}
+
+	_ = s.IStartFlowTimer()
+	if err := s.TheFlowShouldHaveTakenLessThan("1m"); err != nil {
+		t.Errorf("TheFlowShouldHaveTakenLessThan() error = %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := s.TheFlowShouldHaveTakenLessThan("1ms"); err == nil {
+		t.Errorf("TheFlowShouldHaveTakenLessThan() expected error when flow took too long")
+	}
+
+	s.ResetScenario(false)
+	if err := s.TheFlowShouldHaveTakenLessThan("1m"); err == nil {
+		t.Errorf("TheFlowShouldHaveTakenLessThan() expected error after scenario reset")
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldBeOneOfValues(t *testing.T) {
+	body := `{"status": "active", "priority": 2, "ratio": 0.5, "enabled": true}`
+	tests := []struct {
+		name      string
+		expr      string
+		dataType  string
+		valuesCSV string
+		wantErr   bool
+	}{
+		{name: "string in values", expr: "status", dataType: "string", valuesCSV: "pending, active, closed", wantErr: false},
+		{name: "string not in values", expr: "status", dataType: "string", valuesCSV: "pending,closed", wantErr: true},
+		{name: "templated values", expr: "status", dataType: "string", valuesCSV: "pending,{{.STATUS}}", wantErr: false},
+		{name: "int in values", expr: "priority", dataType: "int", valuesCSV: "1,2,3", wantErr: false},
+		{name: "int not in values", expr: "priority", dataType: "int", valuesCSV: "1,3", wantErr: true},
+		{name: "float in values", expr: "ratio", dataType: "float", valuesCSV: "0.25,0.5", wantErr: false},
+		{name: "bool in values", expr: "enabled", dataType: "bool", valuesCSV: "true", wantErr: false},
+		{name: "node of other type", expr: "status", dataType: "int", valuesCSV: "1,2", wantErr: true},
+		{name: "invalid candidate", expr: "priority", dataType: "int", valuesCSV: "one,two", wantErr: true},
+		{name: "unknown type", expr: "status", dataType: "map", valuesCSV: "active", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("STATUS", "active")
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldBeOneOfValues(tt.expr, tt.dataType, tt.valuesCSV); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldBeOneOfValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONResponseShouldNotHaveKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		keys    string
+		wantErr bool
+	}{
+		{name: "no forbidden keys", body: `{"user": {"name": "ivo"}}`, keys: "user.password, user.token", wantErr: false},
+		{name: "one forbidden key", body: `{"user": {"name": "ivo", "token": "abc"}}`, keys: "user.password, user.token", wantErr: true},
+		{name: "not json", body: `<html></html>`, keys: "user.password", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			if err := s.TheJSONResponseShouldNotHaveKeys(tt.keys); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONResponseShouldNotHaveKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISaveLastResponseBodyRedactedAs(t *testing.T) {
+	body := `{"users": [{"name": "ivo", "email": "ivo@example.com"}, {"name": "pawel", "email": "pawel@example.com"}], "token": "abc"}`
+	tests := []struct {
+		name        string
+		expressions string
+		want        string
+		wantErr     bool
+	}{
+		{name: "redacted nodes", expressions: "token, users[0].email, users[1].email",
+			want: `{"token":"***","users":[{"email":"***","name":"ivo"},{"email":"***","name":"pawel"}]}`},
+		{name: "missing nodes are ignored", expressions: "password, users[5].email, token.value",
+			want: `{"token":"abc","users":[{"email":"ivo@example.com","name":"ivo"},{"email":"pawel@example.com","name":"pawel"}]}`},
+		{name: "invalid expression", expressions: "users[x]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			err := s.ISaveLastResponseBodyRedactedAs(tt.expressions, "REDACTED")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ISaveLastResponseBodyRedactedAs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got, _ := s.GetSavedString("REDACTED"); got != tt.want {
+				t.Errorf("redacted body = %s, want %s", got, tt.want)
+			}
+
+			if err := s.TheJSONNodeShouldBeOfValue("users[0].email", "string", "ivo@example.com"); err != nil {
+				t.Errorf("last response body should not be modified: %v", err)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeSliceShouldBeSortedBy(t *testing.T) {
+	body := `{"users": [{"id": 1, "name": "anna"}, {"id": 2, "name": "bob"}, {"id": 2, "name": "carl"}], "mixed": [{"v": 1}, {"v": "a"}], "missing": [{"v": 1}, {"w": 2}], "ids": [3, 2, 1]}`
+	tests := []struct {
+		name      string
+		expr      string
+		subPath   string
+		direction string
+		wantErr   bool
+	}{
+		{name: "ascending numbers", expr: "users", subPath: "id", direction: "asc"},
+		{name: "ascending strings", expr: "users", subPath: "name", direction: "asc"},
+		{name: "not descending", expr: "users", subPath: "id", direction: "desc", wantErr: true},
+		{name: "descending elements", expr: "ids", subPath: "", direction: "desc"},
+		{name: "mixed values", expr: "mixed", subPath: "v", direction: "asc", wantErr: true},
+		{name: "missing sub value", expr: "missing", subPath: "v", direction: "asc", wantErr: true},
+		{name: "not slice", expr: "users[0]", subPath: "id", direction: "asc", wantErr: true},
+		{name: "unknown direction", expr: "users", subPath: "id", direction: "up", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeSliceShouldBeSortedBy(tt.expr, tt.subPath, tt.direction); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeSliceShouldBeSortedBy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeSliceElementsShouldHaveUnique(t *testing.T) {
+	body := `{"users": [{"id": 1, "name": "anna"}, {"id": 2, "name": "bob"}, {"id": 3, "name": "anna"}, {"id": 4, "name": "bob"}], "ids": [1, 2, 2]}`
+	tests := []struct {
+		name       string
+		expr       string
+		subPath    string
+		wantErr    bool
+		duplicates []string
+	}{
+		{name: "unique ids", expr: "users", subPath: "id"},
+		{name: "duplicated names", expr: "users", subPath: "name", wantErr: true, duplicates: []string{`"anna"`, `"bob"`}},
+		{name: "duplicated elements", expr: "ids", subPath: "", wantErr: true, duplicates: []string{"2"}},
+		{name: "missing sub value", expr: "users", subPath: "email", wantErr: true},
+		{name: "not slice", expr: "users[0]", subPath: "id", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			err := s.TheJSONNodeSliceElementsShouldHaveUnique(tt.expr, tt.subPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TheJSONNodeSliceElementsShouldHaveUnique() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, duplicate := range tt.duplicates {
+				if !strings.Contains(err.Error(), duplicate) {
+					t.Errorf("error %v should report duplicated value %s", err, duplicate)
+				}
+			}
+		})
+	}
+}
+
+func TestApiFeature_replaceTemplatedValueSeq(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("NAME", "user")
+
+	replaced, err := s.replaceTemplatedValue(`[{{ range $i, $e := seq 100 }}{{ if $i }},{{ end }}{"id": {{$i}}, "name": "{{$.NAME}}"}{{ end }}]`)
+	if err != nil {
+		t.Fatalf("replaceTemplatedValue() error = %v", err)
+	}
+
+	var elements []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(replaced), &elements); err != nil {
+		t.Fatalf("templated array is not valid JSON: %v", err)
+	}
+
+	if len(elements) != 100 {
+		t.Fatalf("templated array has length %d, expected 100", len(elements))
+	}
+
+	for i, element := range elements {
+		if element.ID != i || element.Name != "user" {
+			t.Errorf("element %d is %+v", i, element)
+		}
+	}
+
+	if _, err := s.replaceTemplatedValue(`{{ range seq -1 }}{{ end }}`); err == nil {
+		t.Errorf("replaceTemplatedValue() should fail for negative seq length")
+	}
+}
+
+func TestApiFeature_TheResponseShouldMatchExample(t *testing.T) {
+	s := &Scenario{}
+	if err := s.RegisterResponseExample("user", `{"id": 1, "name": "ivo", "roles": ["admin"], "address": {"city": "x"}, "deletedAt": null}`); err != nil {
+		t.Fatalf("RegisterResponseExample() error = %v", err)
+	}
+
+	if err := s.RegisterResponseExample("users", `[{"id": 1}]`); err != nil {
+		t.Fatalf("RegisterResponseExample() error = %v", err)
+	}
+
+	if err := s.RegisterResponseExample("invalid", `{"id":`); err == nil {
+		t.Errorf("RegisterResponseExample() should fail for invalid JSON")
+	}
+
+	tests := []struct {
+		name    string
+		example string
+		body    string
+		wantErr string
+	}{
+		{name: "different values and extra keys", example: "user",
+			body: `{"id": 7, "name": "pawel", "roles": [], "address": {"city": "y", "zip": "00-001"}, "deletedAt": "2021-01-01", "extra": true}`},
+		{name: "missing key", example: "user",
+			body: `{"id": 7, "roles": [], "address": {"city": "y"}, "deletedAt": null}`, wantErr: "missing key name"},
+		{name: "different type of nested node", example: "user",
+			body: `{"id": 7, "name": "pawel", "roles": [], "address": {"city": 1}, "deletedAt": null}`, wantErr: "address.city is number, expected string"},
+		{name: "different type of slice element", example: "user",
+			body: `{"id": 7, "name": "pawel", "roles": ["a", 2], "address": {"city": "y"}, "deletedAt": null}`, wantErr: "roles[1] is number, expected string"},
+		{name: "slice of objects", example: "users", body: `[{"id": 1}, {"id": 2, "name": "x"}]`},
+		{name: "slice element missing key", example: "users", body: `[{"id": 1}, {"name": "x"}]`, wantErr: "missing key [1].id"},
+		{name: "root of different type", example: "users", body: `{"id": 1}`, wantErr: "root node is map, expected slice"},
+		{name: "not registered example", example: "order", body: `{}`, wantErr: "not registered"},
+		{name: "invalid JSON response", example: "user", body: `<user/>`, wantErr: ErrJson.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			err := s.TheResponseShouldMatchExample(tt.example)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("TheResponseShouldMatchExample() error = %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("TheResponseShouldMatchExample() error = %v, want error containing %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISetMaxResponseBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name": "ivo"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		limit   int64
+		wantErr bool
+	}{
+		{name: "no limit", limit: 0},
+		{name: "body within limit", limit: 15},
+		{name: "body exceeds limit", limit: 14, wantErr: true},
+		{name: "negative limit", limit: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			err := s.ISetMaxResponseBodySize(tt.limit)
+			if err == nil {
+				err = s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {}}`})
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				if err := s.TheJSONNodeShouldBeOfValue("name", "string", "ivo"); err != nil {
+					t.Errorf("last response body should be readable: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseBodyShouldEqualFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "expected.bin")
+	if err := ioutil.WriteFile(filePath, []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    []byte
+		file    string
+		wantErr string
+	}{
+		{name: "equal bytes", body: []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, file: "file://" + filePath},
+		{name: "different byte", body: []byte{0x89, 'P', 'N', 'G', 0x00, 0x02}, file: filePath, wantErr: "byte offset 5: got 0x02, expected 0x01"},
+		{name: "shorter body", body: []byte{0x89, 'P', 'N'}, file: filePath, wantErr: "response body has 3 bytes"},
+		{name: "missing file", body: []byte{}, file: filePath + ".missing", wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.body))}
+			err := s.TheResponseBodyShouldEqualFile(tt.file)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("TheResponseBodyShouldEqualFile() error = %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("TheResponseBodyShouldEqualFile() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseBodyShouldBeFileType(t *testing.T) {
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, _ = gzipWriter.Write([]byte("content"))
+	_ = gzipWriter.Close()
+
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	_, _ = zipWriter.Create("file.txt")
+	_ = zipWriter.Close()
+
+	var pngImage bytes.Buffer
+	_ = png.Encode(&pngImage, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+
+	tests := []struct {
+		name     string
+		body     []byte
+		fileType string
+		wantErr  bool
+	}{
+		{name: "pdf", body: []byte("%PDF-1.7\n%\xe2\xe3"), fileType: "pdf"},
+		{name: "zip", body: zipped.Bytes(), fileType: "zip"},
+		{name: "empty zip", body: []byte("PK\x05\x06"), fileType: "ZIP"},
+		{name: "png", body: pngImage.Bytes(), fileType: "png"},
+		{name: "gzip", body: gzipped.Bytes(), fileType: "gzip"},
+		{name: "json is not pdf", body: []byte(`{"a": 1}`), fileType: "pdf", wantErr: true},
+		{name: "gzip is not zip", body: gzipped.Bytes(), fileType: "zip", wantErr: true},
+		{name: "empty body", body: []byte{}, fileType: "png", wantErr: true},
+		{name: "unknown file type", body: []byte("%PDF-"), fileType: "docx", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewReader(tt.body))}
+			if err := s.TheResponseBodyShouldBeFileType(tt.fileType); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseBodyShouldBeFileType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeadersHost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"host": "%s", "hostHeader": "%s"}`, r.Host, r.Header.Get("Host"))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("VHOST", "api.example.com")
+	err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {"host": "{{.VHOST}}"}}`})
+	if err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("host", "string", "api.example.com"); err != nil {
+		t.Errorf("server should receive host from Host header: %v", err)
+	}
+}
+
+func TestApiFeature_TheResponseShouldNotHaveRedirectedToDifferentHost(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer target.Close()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/external":
+			http.Redirect(w, r, target.URL+"/landing", http.StatusFound)
+		case "/internal":
+			http.Redirect(w, r, srv.URL+"/landing", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "no redirect", path: "/landing"},
+		{name: "redirect to same host", path: "/internal"},
+		{name: "redirect to different host", path: "/external", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+tt.path, &godog.DocString{Content: `{"body": {}, "headers": {}}`})
+			if err != nil {
+				t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+			}
+
+			if err := s.TheResponseShouldNotHaveRedirectedToDifferentHost(); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseShouldNotHaveRedirectedToDifferentHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.TheResponseShouldNotHaveRedirectedToDifferentHost(); err == nil {
+		t.Errorf("TheResponseShouldNotHaveRedirectedToDifferentHost() should fail without sent request")
+	}
+}
+
+func TestApiFeature_ILoadEnvironmentConfigFromFile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "environments.json")
+	config := `{"dev": {"HOST": "http://localhost:8080", "AUTH": {"token": "dev-token"}}, "staging": {"HOST": "https://staging.example.com"}}`
+	if err := ioutil.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	invalidPath := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalidPath, []byte(`{"dev": "http://localhost"}`), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ILoadEnvironmentConfigFromFile("file://"+configPath, "dev"); err != nil {
+		t.Fatalf("ILoadEnvironmentConfigFromFile() error = %v", err)
+	}
+
+	replaced, err := s.replaceTemplatedValue(`{{.HOST}}/users?token={{.AUTH.token}}`)
+	if err != nil {
+		t.Fatalf("replaceTemplatedValue() error = %v", err)
+	}
+
+	if replaced != "http://localhost:8080/users?token=dev-token" {
+		t.Errorf("templated value = %s", replaced)
+	}
+
+	err = s.ILoadEnvironmentConfigFromFile(configPath, "prod")
+	if err == nil || !strings.Contains(err.Error(), "available environments: dev, staging") {
+		t.Errorf("ILoadEnvironmentConfigFromFile() error = %v, expected error listing available environments", err)
+	}
+
+	if err := s.ILoadEnvironmentConfigFromFile(invalidPath, "dev"); err == nil {
+		t.Errorf("ILoadEnvironmentConfigFromFile() should fail for environment which is not object")
+	}
+
+	if err := s.ILoadEnvironmentConfigFromFile(filepath.Join(dir, "environments.yaml"), "dev"); err == nil {
+		t.Errorf("ILoadEnvironmentConfigFromFile() should fail for unsupported file format")
+	}
+}
+
+func TestApiFeature_TheResponseCompressionRatioShouldBeAtLeast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := []byte(`{"data": "` + strings.Repeat("a", 1000) + `"}`)
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			_, _ = w.Write(content)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gzipWriter := gzip.NewWriter(w)
+		_, _ = gzipWriter.Write(content)
+		_ = gzipWriter.Close()
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		headers string
+		ratio   float64
+		wantErr bool
+	}{
+		{name: "effective compression", headers: `{"Accept-Encoding": "gzip"}`, ratio: 10},
+		{name: "compression below ratio", headers: `{"Accept-Encoding": "gzip"}`, ratio: 1000, wantErr: true},
+		{name: "transparently decompressed", headers: `{}`, ratio: 1, wantErr: true},
+		{name: "not compressed", headers: `{"Accept-Encoding": "identity"}`, ratio: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": ` + tt.headers + `}`})
+			if err != nil {
+				t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+			}
+
+			if err := s.TheResponseCompressionRatioShouldBeAtLeast(tt.ratio); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseCompressionRatioShouldBeAtLeast() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ETagFlow(t *testing.T) {
+	version := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			version++
+		}
+
+		if r.URL.Path != "/no-etag" {
+			w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	send := func(method, path string) {
+		t.Helper()
+		if err := s.ISendRequestToWithBodyAndHeaders(method, srv.URL+path, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	send(http.MethodGet, "/resource")
+	if err := s.ISaveETagAs("ETAG"); err != nil {
+		t.Fatalf("ISaveETagAs() error = %v", err)
+	}
+
+	send(http.MethodGet, "/resource")
+	if err := s.TheETagShouldEqualCached("ETAG"); err != nil {
+		t.Errorf("ETag should be stable across reads: %v", err)
+	}
+
+	send(http.MethodPut, "/resource")
+	send(http.MethodGet, "/resource")
+	if err := s.TheETagShouldEqualCached("ETAG"); err == nil {
+		t.Errorf("ETag should change after write")
+	}
+
+	send(http.MethodGet, "/no-etag")
+	if err := s.ISaveETagAs("OTHER"); err == nil {
+		t.Errorf("ISaveETagAs() should fail for response without ETag")
+	}
+
+	if err := s.TheETagShouldEqualCached("ETAG"); err == nil {
+		t.Errorf("TheETagShouldEqualCached() should fail for response without ETag")
+	}
+
+	if err := s.TheETagShouldEqualCached("MISSING"); err == nil {
+		t.Errorf("TheETagShouldEqualCached() should fail for missing cache key")
+	}
+}
+
+func TestApiFeature_replaceTemplatedValueHTTPDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "time", date: time.Date(2021, 3, 4, 12, 30, 0, 0, time.FixedZone("CET", 3600)), want: "Thu, 04 Mar 2021 11:30:00 GMT"},
+		{name: "RFC3339 string", date: "2021-03-04T11:30:00Z", want: "Thu, 04 Mar 2021 11:30:00 GMT"},
+		{name: "not date string", date: "yesterday", wantErr: true},
+		{name: "number", date: 1614857400, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("DATE", tt.date)
+			got, err := s.replaceTemplatedValue(`{{ httpDate .DATE }}`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replaceTemplatedValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("replaceTemplatedValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseVaryHeaderShouldContain(t *testing.T) {
+	tests := []struct {
+		name       string
+		vary       []string
+		headerName string
+		wantErr    bool
+	}{
+		{name: "single value", vary: []string{"Accept-Encoding"}, headerName: "Accept-Encoding"},
+		{name: "case insensitive", vary: []string{"accept-language, Origin"}, headerName: "Accept-Language"},
+		{name: "multiple header lines", vary: []string{"Origin", "Accept-Encoding,Accept"}, headerName: "accept"},
+		{name: "missing header name", vary: []string{"Accept-Encoding, Origin"}, headerName: "Accept", wantErr: true},
+		{name: "no Vary header", headerName: "Origin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Header: http.Header{}}
+			for _, vary := range tt.vary {
+				s.lastResponse.Header.Add("Vary", vary)
+			}
+
+			if err := s.TheResponseVaryHeaderShouldContain(tt.headerName); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseVaryHeaderShouldContain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseUpstreamHitCountHeaderShouldBe(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		count   int
+		wantErr bool
+	}{
+		{name: "equal count", header: "1", count: 1},
+		{name: "different count", header: "3", count: 1, wantErr: true},
+		{name: "not integer", header: "one", count: 1, wantErr: true},
+		{name: "missing header", header: "", count: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				s.lastResponse.Header.Set("X-Upstream-Hits", tt.header)
+			}
+
+			if err := s.TheResponseUpstreamHitCountHeaderShouldBe("X-Upstream-Hits", tt.count); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseUpstreamHitCountHeaderShouldBe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseShouldHaveNumericHeader(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		value          float64
+		wantEqualErr   bool
+		wantGreaterErr bool
+		wantLessErr    bool
+	}{
+		{name: "equal", header: "120", value: 120, wantGreaterErr: true, wantLessErr: true},
+		{name: "greater", header: "120.5", value: 100, wantEqualErr: true, wantLessErr: true},
+		{name: "less", header: " 0 ", value: 1, wantEqualErr: true, wantGreaterErr: true},
+		{name: "not number", header: "soon", value: 1, wantEqualErr: true, wantGreaterErr: true, wantLessErr: true},
+		{name: "missing header", header: "", value: 0, wantEqualErr: true, wantGreaterErr: true, wantLessErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				s.lastResponse.Header.Set("Age", tt.header)
+			}
+
+			if err := s.TheResponseShouldHaveNumericHeaderOfValue("Age", tt.value); (err != nil) != tt.wantEqualErr {
+				t.Errorf("TheResponseShouldHaveNumericHeaderOfValue() error = %v, wantErr %v", err, tt.wantEqualErr)
+			}
+
+			if err := s.TheResponseShouldHaveNumericHeaderGreaterThan("Age", tt.value); (err != nil) != tt.wantGreaterErr {
+				t.Errorf("TheResponseShouldHaveNumericHeaderGreaterThan() error = %v, wantErr %v", err, tt.wantGreaterErr)
+			}
+
+			if err := s.TheResponseShouldHaveNumericHeaderLessThan("Age", tt.value); (err != nil) != tt.wantLessErr {
+				t.Errorf("TheResponseShouldHaveNumericHeaderLessThan() error = %v, wantErr %v", err, tt.wantLessErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseContentLengthShouldMatchBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "20")
+		_, _ = w.Write([]byte(`{"truncated": true}`))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheResponseContentLengthShouldMatchBody(); err == nil {
+		t.Errorf("TheResponseContentLengthShouldMatchBody() should fail for truncated body")
+	}
+
+	tests := []struct {
+		name          string
+		contentLength int64
+		body          string
+		wantErr       bool
+	}{
+		{name: "matching length", contentLength: 2, body: `{}`},
+		{name: "body longer than declared", contentLength: 1, body: `{}`, wantErr: true},
+		{name: "unknown length", contentLength: -1, body: `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.lastResponse = &http.Response{ContentLength: tt.contentLength, Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			if err := s.TheResponseContentLengthShouldMatchBody(); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseContentLengthShouldMatchBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheStreamedJSONLinesNodeShouldBeIncreasing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		switch r.URL.Path {
+		case "/increasing":
+			for _, line := range []string{`{"seq": 1}`, ``, `{"seq": 1}`, `{"seq": 2.5}`} {
+				_, _ = fmt.Fprintln(w, line)
+				flusher.Flush()
+			}
+		case "/decreasing":
+			_, _ = fmt.Fprint(w, "{\"seq\": 2}\n{\"seq\": 1}\n")
+		case "/not-number":
+			_, _ = fmt.Fprint(w, "{\"seq\": \"1\"}\n")
+		case "/endless":
+			for i := 0; ; i++ {
+				if _, err := fmt.Fprintf(w, "{\"seq\": %d}\n", i); err != nil {
+					return
+				}
+				flusher.Flush()
+
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		interval string
+		wantErr  bool
+	}{
+		{name: "increasing stream", path: "/increasing", interval: "1s"},
+		{name: "stream consumed for interval", path: "/endless", interval: "100ms"},
+		{name: "decreasing stream", path: "/decreasing", interval: "1s", wantErr: true},
+		{name: "node is not number", path: "/not-number", interval: "1s", wantErr: true},
+		{name: "empty stream", path: "/empty", interval: "1s", wantErr: true},
+		{name: "invalid interval", path: "/increasing", interval: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("HOST", srv.URL)
+			if err := s.TheStreamedJSONLinesNodeShouldBeIncreasing("{{.HOST}}"+tt.path, "seq", tt.interval); (err != nil) != tt.wantErr {
+				t.Errorf("TheStreamedJSONLinesNodeShouldBeIncreasing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_signAWSSigV4(t *testing.T) {
+	creds := awsSigV4Credentials{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", region: "us-east-1", service: "service"}
+	signingTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	//expected values come from get-vanilla and get-vanilla-query-order-key-case cases of AWS Signature Version 4 test suite
+	tests := []struct {
+		name          string
+		url           string
+		authorization string
+	}{
+		{name: "vanilla", url: "https://example.amazonaws.com/",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{name: "query order", url: "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest() error = %v", err)
+			}
+
+			if _, err = signAWSSigV4(req, creds, signingTime); err != nil {
+				t.Fatalf("signAWSSigV4() error = %v", err)
+			}
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %s", got)
+			}
+
+			if got := req.Header.Get("Authorization"); got != tt.authorization {
+				t.Errorf("Authorization = %s, want %s", got, tt.authorization)
+			}
+		})
+	}
+}
+
+func Test_awsSigV4CanonicalURI(t *testing.T) {
+	//expected values follow canonical URI example from AWS Signature Version 4 documentation
+	tests := []struct {
+		name    string
+		url     string
+		service string
+		want    string
+	}{
+		{name: "empty path", url: "https://example.amazonaws.com", service: "service", want: "/"},
+		{name: "root path", url: "https://example.amazonaws.com/", service: "service", want: "/"},
+		{name: "segments encoded twice", url: "https://example.amazonaws.com/documents%20and%20settings/", service: "service", want: "/documents%2520and%2520settings/"},
+		{name: "segments encoded once for s3", url: "https://bucket.s3.amazonaws.com/documents%20and%20settings/", service: "s3", want: "/documents%20and%20settings/"},
+		{name: "reserved characters", url: "https://example.amazonaws.com/a$b/c%2Fd/e~f", service: "service", want: "/a%2524b/c%252Fd/e~f"},
+		{name: "reserved characters for s3", url: "https://bucket.s3.amazonaws.com/a$b/c%2Fd/e~f", service: "s3", want: "/a%24b/c%2Fd/e~f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+
+			if got := awsSigV4CanonicalURI(u, tt.service); got != tt.want {
+				t.Errorf("awsSigV4CanonicalURI() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/documents%20and%20settings/", nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest() error = %v", err)
+	}
+
+	creds := awsSigV4Credentials{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", region: "us-east-1", service: "service"}
+	canonicalRequest, err := signAWSSigV4(req, creds, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("signAWSSigV4() error = %v", err)
+	}
+
+	if !strings.HasPrefix(canonicalRequest, "GET\n/documents%2520and%2520settings/\n") {
+		t.Errorf("signAWSSigV4() canonical request = %q, expected path encoded twice", canonicalRequest)
+	}
+}
+
+func TestApiFeature_ISetAWSSigV4Signing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"authorization": %q}`, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("ACCESS_KEY", "AKIDEXAMPLE")
+	if err := s.ISetAWSSigV4Signing("{{.ACCESS_KEY}}", "secret", "eu-west-1", "execute-api"); err != nil {
+		t.Fatalf("ISetAWSSigV4Signing() error = %v", err)
+	}
+
+	err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, srv.URL, &godog.DocString{Content: `{"body": {"a": 1}, "headers": {"Content-Type": "application/json"}}`})
+	if err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	authorization, err := s.resolveJSONString("authorization")
+	if err != nil {
+		t.Fatalf("resolveJSONString() error = %v", err)
+	}
+
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(authorization, "/eu-west-1/execute-api/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=") {
+		t.Errorf("Authorization = %s", authorization)
+	}
+
+	s.ResetScenario(false)
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("authorization", "string", ""); err != nil {
+		t.Errorf("requests should not be signed after scenario reset: %v", err)
+	}
+}
+
+func TestApiFeature_IPrintCanonicalRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	recorder := &RecordingDebugger{}
+	s := &Scenario{}
+	s.SetDebugOutput(recorder)
+	s.ResetScenario(false)
+	if err := s.IPrintCanonicalRequest(); err == nil {
+		t.Errorf("IPrintCanonicalRequest() should fail when no request was signed")
+	}
+
+	if err := s.ISetAWSSigV4Signing("AKIDEXAMPLE", "secret", "us-east-1", "service"); err != nil {
+		t.Fatalf("ISetAWSSigV4Signing() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+"/users?b=2&a=1", &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.IPrintCanonicalRequest(); err != nil {
+		t.Fatalf("IPrintCanonicalRequest() error = %v", err)
+	}
+
+	messages := recorder.Messages()
+	if len(messages) != 1 || !strings.Contains(messages[0], "GET\n/users\na=1&b=2\nhost:") {
+		t.Errorf("debug messages = %q, expected canonical request", messages)
+	}
+}
+
+func TestApiFeature_TheResponseShouldHaveHeaderValues(t *testing.T) {
+	expiringCookie := "session=abc; Expires=Wed, 21 Oct 2026 07:28:00 GMT; HttpOnly"
+	tests := []struct {
+		name    string
+		values  string
+		wantErr bool
+	}{
+		{name: "same values", values: expiringCookie + "\ntheme=dark"},
+		{name: "different order and surrounding white spaces", values: "  theme=dark\n\n" + expiringCookie + "  \n"},
+		{name: "missing value", values: expiringCookie, wantErr: true},
+		{name: "unexpected value", values: expiringCookie + "\ntheme=dark\nlang=pl", wantErr: true},
+		{name: "different expiration date", values: "session=abc; Expires=Thu, 22 Oct 2026 07:28:00 GMT; HttpOnly\ntheme=dark", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Header: http.Header{}}
+			s.lastResponse.Header.Add("Set-Cookie", expiringCookie)
+			s.lastResponse.Header.Add("Set-Cookie", "theme=dark")
+
+			if err := s.TheResponseShouldHaveHeaderValues("Set-Cookie", &godog.DocString{Content: tt.values}); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseShouldHaveHeaderValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeSliceDatesShouldBeAscending(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "ascending dates", body: `{"events": [{"at": "2021-01-01T10:00:00Z"}, {"at": "2021-01-01T10:00:00Z"}, {"at": "2021-01-01T12:00:00+01:00"}]}`},
+		{name: "descending dates", body: `{"events": [{"at": "2021-01-02T10:00:00Z"}, {"at": "2021-01-01T10:00:00Z"}]}`, wantErr: "not in chronological order"},
+		{name: "unparseable date", body: `{"events": [{"at": "2021-01-01T10:00:00Z"}, {"at": "yesterday"}]}`, wantErr: "yesterday"},
+		{name: "not string", body: `{"events": [{"at": 1609495200}]}`, wantErr: "expected string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+			err := s.TheJSONNodeSliceDatesShouldBeAscending("events", "at")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("TheJSONNodeSliceDatesShouldBeAscending() error = %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("TheJSONNodeSliceDatesShouldBeAscending() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONResponseShouldSatisfy(t *testing.T) {
+	body := `{"null": null, "false": false, "true": true, "zero": 0, "number": 0.5, "empty": "", "text": "a", "emptySlice": [], "slice": [0], "emptyMap": {}, "map": {"a": null}}`
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "null", wantErr: true},
+		{expr: "false", wantErr: true},
+		{expr: "true"},
+		{expr: "zero", wantErr: true},
+		{expr: "number"},
+		{expr: "empty", wantErr: true},
+		{expr: "text"},
+		{expr: "emptySlice", wantErr: true},
+		{expr: "slice"},
+		{expr: "emptyMap", wantErr: true},
+		{expr: "map"},
+		{expr: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONResponseShouldSatisfy(tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONResponseShouldSatisfy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_IExportCacheToJUnitProperties(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "properties.xml")
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("USER_ID", "a&b")
+	s.Save("COUNT", 3)
+	s.Save("CREATED", time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	s.Save("USER", map[string]interface{}{"id": 1})
+	s.Save("PASSWORD", "secret")
+	s.Save("CALLBACK", func() {})
+
+	if err := s.IExportCacheToJUnitProperties("USER_ID, COUNT,CREATED,USER", "file://"+filePath); err != nil {
+		t.Fatalf("IExportCacheToJUnitProperties() error = %v", err)
+	}
+
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("could not read file: %v", err)
+	}
+
+	var properties junitProperties
+	if err = xml.Unmarshal(content, &properties); err != nil {
+		t.Fatalf("file is not valid XML: %v", err)
+	}
+
+	want := []junitProperty{
+		{Name: "USER_ID", Value: "a&b"},
+		{Name: "COUNT", Value: "3"},
+		{Name: "CREATED", Value: "2021-01-02T03:04:05Z"},
+		{Name: "USER", Value: `{"id":1}`},
+	}
+	if !reflect.DeepEqual(properties.Properties, want) {
+		t.Errorf("exported properties = %+v, want %+v", properties.Properties, want)
+	}
+
+	tests := []struct {
+		name    string
+		keysCSV string
+		wantErr error
+	}{
+		{name: "missing key", keysCSV: "USER_ID,TOKEN", wantErr: ErrPreservedData},
+		{name: "value which can't be serialized", keysCSV: "CALLBACK"},
+		{name: "no keys", keysCSV: " , "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.IExportCacheToJUnitProperties(tt.keysCSV, "file://"+filepath.Join(t.TempDir(), "properties.xml"))
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("IExportCacheToJUnitProperties() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheTwoResponsesShouldDiffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		last     string
+		wantErr  bool
+	}{
+		{name: "different JSON", previous: `{"a": 1}`, last: `{"a": 2}`},
+		{name: "JSON differing only in formatting", previous: `{"a": 1, "b": [1, 2]}`, last: "{\n  \"b\": [1,2],\n  \"a\": 1\n}", wantErr: true},
+		{name: "different text", previous: `abc`, last: `abd`},
+		{name: "equal text", previous: `abc`, last: `abc`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.previousResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.previous))}
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.last))}
+			if err := s.TheTwoResponsesShouldDiffer(); (err != nil) != tt.wantErr {
+				t.Errorf("TheTwoResponsesShouldDiffer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.TheTwoResponsesShouldDiffer(); err == nil {
+		t.Errorf("TheTwoResponsesShouldDiffer() should fail without previous response")
+	}
+}
+
+func TestApiFeature_IEnableDryRun(t *testing.T) {
+	rt := &countingRoundTripper{next: DefaultTransport()}
+	s := &Scenario{}
+	s.SetRoundTripper(rt)
+	s.ResetScenario(false)
+	s.Save("ID", 7)
+
+	if err := s.IEnableDryRun(); err != nil {
+		t.Fatalf("IEnableDryRun() error = %v", err)
+	}
+
+	request := &godog.DocString{Content: `{"body": {"a": 1}, "headers": {}}`}
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, "http://unreachable.invalid/users", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheResponseStatusCodeShouldBe(http.StatusOK); err != nil {
+		t.Errorf("dry run response: %v", err)
+	}
+
+	if body := s.GetLastResponseBody(); len(body) != 0 {
+		t.Errorf("dry run response body = %s, expected empty", body)
+	}
+
+	if err := s.IEnableDryRunWithResponseBody(&godog.DocString{Content: `{"id": {{.ID}}}`}); err != nil {
+		t.Fatalf("IEnableDryRunWithResponseBody() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, "http://unreachable.invalid/users/7", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("id", "int", "7"); err != nil {
+		t.Errorf("dry run response should have canned body: %v", err)
+	}
+
+	if rt.count != 0 {
+		t.Errorf("round tripper was called %d times in dry run", rt.count)
+	}
+}
+
+func TestApiFeature_ISetCannedResponseForNextSend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"source": "server"}`))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("ID", 3)
+	if err := s.ISetCannedResponseForNextSend(http.StatusCreated, `{"id": {{.ID}}}`, `{"Location": "/users/{{.ID}}"}`); err != nil {
+		t.Fatalf("ISetCannedResponseForNextSend() error = %v", err)
+	}
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, srv.URL, request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheResponseStatusCodeShouldBe(http.StatusCreated); err != nil {
+		t.Errorf("canned response: %v", err)
+	}
+
+	if err := s.TheResponseShouldHaveHeaderOfValue("Location", "/users/3"); err != nil {
+		t.Errorf("canned response: %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("id", "int", "3"); err != nil {
+		t.Errorf("canned response: %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("source", "string", "server"); err != nil {
+		t.Errorf("canned response should be used only once: %v", err)
+	}
+
+	if err := s.ISetCannedResponseForNextSend(http.StatusOK, `{}`, `["Location"]`); err == nil {
+		t.Errorf("ISetCannedResponseForNextSend() should fail for headers which are not JSON object")
+	}
+}
+
+func TestApiFeature_IReplayFromHARFile(t *testing.T) {
+	dir := t.TempDir()
+	harPath := filepath.Join(dir, "recording.har")
+	har := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "http://api.example.com/users/1"},
+		 "response": {"status": 200, "headers": [{"name": "Content-Type", "value": "application/json"}], "content": {"text": "{\"id\": 1}"}}},
+		{"request": {"method": "DELETE", "url": "http://api.example.com/users/1"},
+		 "response": {"status": 204, "headers": [], "content": {"text": ""}}},
+		{"request": {"method": "GET", "url": "http://api.example.com/avatar"},
+		 "response": {"status": 200, "headers": [], "content": {"text": "iVBORw==", "encoding": "base64"}}}
+	]}}`
+	if err := ioutil.WriteFile(harPath, []byte(har), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.IReplayFromHARFile("file://" + harPath); err != nil {
+		t.Fatalf("IReplayFromHARFile() error = %v", err)
+	}
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, "http://api.example.com/users/1", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("id", "int", "1"); err != nil {
+		t.Errorf("replayed response: %v", err)
+	}
+
+	if err := s.TheResponseShouldHaveHeaderOfValue("Content-Type", "application/json"); err != nil {
+		t.Errorf("replayed response: %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodDelete, "http://api.example.com/users/1", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheResponseStatusCodeShouldBe(http.StatusNoContent); err != nil {
+		t.Errorf("replayed response: %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, "http://api.example.com/avatar", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if body := s.GetLastResponseBody(); !bytes.Equal(body, []byte{0x89, 'P', 'N', 'G'}) {
+		t.Errorf("replayed response body = %v, expected decoded base64 content", body)
+	}
+
+	err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, "http://api.example.com/users", request)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response for POST http://api.example.com/users") {
+		t.Errorf("ISendRequestToWithBodyAndHeaders() error = %v, expected error about unmatched request", err)
+	}
+
+	if err := s.IReplayFromHARFile(filepath.Join(dir, "missing.har")); err == nil {
+		t.Errorf("IReplayFromHARFile() should fail for missing file")
+	}
+}
+
+func TestApiFeature_TheRequestQueryParamShouldBe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/landing?page=2", http.StatusFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		param   string
+		value   string
+		wantErr bool
+	}{
+		{name: "decoded value", url: "/search?q=a%20b%26c&page=1", param: "q", value: "a b&c"},
+		{name: "one of repeated values", url: "/search?tag=a&tag=b", param: "tag", value: "b"},
+		{name: "different value", url: "/search?page=1", param: "page", value: "2", wantErr: true},
+		{name: "missing param", url: "/search?page=1", param: "q", value: "a", wantErr: true},
+		{name: "request before redirect", url: "/redirect?page=1", param: "page", value: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+tt.url, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+				t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+			}
+
+			if err := s.TheRequestQueryParamShouldBe(tt.param, tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("TheRequestQueryParamShouldBe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeDecodedShouldBe(t *testing.T) {
+	body := `{"encoded": "a%20b%26c%3Dd", "plus": "a+b%2Bc", "malformed": "100%", "base64": "aGVsbG8gd29ybGQ=", "notBase64": "***", "number": 1}`
+	tests := []struct {
+		name     string
+		decode   string
+		expr     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "URL decoded", decode: "url", expr: "encoded", expected: "a b&c=d"},
+		{name: "URL decoded templated", decode: "url", expr: "encoded", expected: "a b&c={{.VALUE}}"},
+		{name: "URL decoded different", decode: "url", expr: "encoded", expected: "a%20b", wantErr: true},
+		{name: "plus is not decoded to space", decode: "url", expr: "plus", expected: "a+b+c"},
+		{name: "plus decoded to space", decode: "url", expr: "plus", expected: "a b+c", wantErr: true},
+		{name: "malformed percent encoding", decode: "url", expr: "malformed", expected: "100%", wantErr: true},
+		{name: "URL decoded not string", decode: "url", expr: "number", expected: "1", wantErr: true},
+		{name: "base64 decoded", decode: "base64", expr: "base64", expected: "hello world"},
+		{name: "base64 decoded different", decode: "base64", expr: "base64", expected: "hello", wantErr: true},
+		{name: "malformed base64", decode: "base64", expr: "notBase64", expected: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("VALUE", "d")
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+
+			var err error
+			if tt.decode == "url" {
+				err = s.TheJSONNodeURLDecodedShouldBe(tt.expr, tt.expected)
+			} else {
+				err = s.TheJSONNodeBase64DecodedShouldBe(tt.expr, tt.expected)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeBase64DecodedShouldHaveFormat(t *testing.T) {
+	//"std" has padding and characters from standard alphabet, "url" is URL-safe without padding
+	body := `{"std": "eyJhIjoiPj4/In0=", "url": "eyJhIjoiPj4_In0", "xml": "PHVzZXIvPg==", "text": "aGVsbG8=", "invalid": "***"}`
+	tests := []struct {
+		name    string
+		expr    string
+		format  string
+		wantErr bool
+	}{
+		{name: "standard base64 JSON", expr: "std", format: "JSON"},
+		{name: "URL-safe base64 JSON", expr: "url", format: "JSON"},
+		{name: "XML", expr: "xml", format: "XML"},
+		{name: "XML is not JSON", expr: "xml", format: "JSON", wantErr: true},
+		{name: "text is not XML", expr: "text", format: "XML", wantErr: true},
+		{name: "invalid base64", expr: "invalid", format: "JSON", wantErr: true},
+		{name: "unknown format", expr: "std", format: "YAML", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeBase64DecodedShouldHaveFormat(tt.expr, tt.format); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeBase64DecodedShouldHaveFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISaveJSONNodeBase64DecodedAs(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"token": "eyJhIjoiPj4_In0", "invalid": "***"}`))}
+	if err := s.ISaveJSONNodeBase64DecodedAs("token", "PAYLOAD"); err != nil {
+		t.Fatalf("ISaveJSONNodeBase64DecodedAs() error = %v", err)
+	}
+
+	payload, err := s.GetSavedString("PAYLOAD")
+	if err != nil {
+		t.Fatalf("GetSavedString() error = %v", err)
+	}
+
+	var decoded map[string]string
+	if err = json.Unmarshal([]byte(payload), &decoded); err != nil || decoded["a"] != ">>?" {
+		t.Errorf("decoded payload = %s, error = %v", payload, err)
+	}
+
+	if err := s.ISaveJSONNodeBase64DecodedAs("invalid", "PAYLOAD"); err == nil {
+		t.Errorf("ISaveJSONNodeBase64DecodedAs() should fail for invalid base64")
+	}
+}
+
+func TestApiFeature_ISendRequestToTimesAndAssertP95(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 20 {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISendRequestToTimesAndAssertP95(http.MethodGet, srv.URL, 20, "40ms", request); err != nil {
+		t.Errorf("single slow request should not exceed 95th percentile: %v", err)
+	}
+
+	durations, err := s.GetSaved("REQUEST_DURATIONS")
+	if err != nil {
+		t.Fatalf("GetSaved() error = %v", err)
+	}
+
+	if d, ok := durations.([]time.Duration); !ok || len(d) != 20 || d[19] < 50*time.Millisecond {
+		t.Errorf("saved durations = %v", durations)
+	}
+
+	calls = 0
+	if err := s.ISendRequestToTimesAndAssertP95(http.MethodGet, srv.URL, 10, "40ms", request); err != nil {
+		t.Errorf("ISendRequestToTimesAndAssertP95() error = %v", err)
+	}
+
+	calls = 18
+	if err := s.ISendRequestToTimesAndAssertP95(http.MethodGet, srv.URL, 10, "40ms", request); err == nil {
+		t.Errorf("slow request among 10 should exceed 95th percentile")
+	}
+
+	if err := s.ISendRequestToTimesAndAssertP95(http.MethodGet, srv.URL, 0, "40ms", request); err == nil {
+		t.Errorf("ISendRequestToTimesAndAssertP95() should fail for 0 requests")
+	}
+}
+
+func TestApiFeature_Guard(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"name": "ivo"}`))}
+	s.Save("HAS_EMAIL", false)
+	s.Save("HAS_NAME", true)
+	s.Save("NOT_BOOL", "false")
+
+	guarded, ok := s.Guard(s.TheJSONNodeShouldBeOfValue).(func(string, string, string) error)
+	if !ok {
+		t.Fatalf("Guard() should return function of the same type as step")
+	}
+
+	if err := s.ISkipGuardedStepsIfCachedValueIsFalse("HAS_EMAIL"); err != nil {
+		t.Fatalf("ISkipGuardedStepsIfCachedValueIsFalse() error = %v", err)
+	}
+
+	if err := guarded("name", "string", "pawel"); err != nil {
+		t.Errorf("guarded step should be skipped, error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("name", "string", "pawel"); err == nil {
+		t.Errorf("step which is not guarded should not be skipped")
+	}
+
+	if err := s.IStopSkippingGuardedSteps(); err != nil {
+		t.Fatalf("IStopSkippingGuardedSteps() error = %v", err)
+	}
+
+	if err := guarded("name", "string", "pawel"); err == nil {
+		t.Errorf("guarded step should be run after guarded block")
+	}
+
+	if err := s.ISkipGuardedStepsIfCachedValueIsFalse("HAS_NAME"); err != nil {
+		t.Fatalf("ISkipGuardedStepsIfCachedValueIsFalse() error = %v", err)
+	}
+
+	if err := guarded("name", "string", "pawel"); err == nil {
+		t.Errorf("guarded step should be run when cached value is true")
+	}
+
+	if err := s.ISkipGuardedStepsIfCachedValueIsFalse("NOT_BOOL"); err == nil {
+		t.Errorf("ISkipGuardedStepsIfCachedValueIsFalse() should fail for value which is not bool")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Guard() should panic for function not returning error")
+		}
+	}()
+	s.Guard(func() {})
+}
+
+func TestApiFeature_TheCreatedResourceShouldBeRetrievable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/users":
+			w.Header().Set("Location", r.URL.Query().Get("location"))
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/users/1":
+			_, _ = w.Write([]byte(`{"id": 1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name           string
+		location       string
+		expectedStatus int
+		wantErr        bool
+	}{
+		{name: "relative location", location: "users/1", expectedStatus: http.StatusOK},
+		{name: "absolute path location", location: "/api/users/1", expectedStatus: http.StatusOK},
+		{name: "absolute URL location", location: srv.URL + "/api/users/1", expectedStatus: http.StatusOK},
+		{name: "not retrievable resource", location: "/api/users/2", expectedStatus: http.StatusOK, wantErr: true},
+		{name: "missing location", location: "", expectedStatus: http.StatusOK, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			createURL := srv.URL + "/api/users?location=" + url.QueryEscape(tt.location)
+			if err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, createURL, &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+				t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+			}
+
+			err := s.TheCreatedResourceShouldBeRetrievable(tt.expectedStatus)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TheCreatedResourceShouldBeRetrievable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				if err := s.TheJSONNodeShouldBeOfValue("id", "int", "1"); err != nil {
+					t.Errorf("fetched resource should become last response: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldEqualHashOfCachedValue(t *testing.T) {
+	body := `{"md5": "5d41402abc4b2a76b9719d911017c592", "sha1": "AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D", "sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", "number": 1}`
+	tests := []struct {
+		name      string
+		expr      string
+		algorithm string
+		cached    interface{}
+		wantErr   bool
+	}{
+		{name: "md5", expr: "md5", algorithm: "md5", cached: "hello"},
+		{name: "upper case sha1", expr: "sha1", algorithm: "sha1", cached: "hello"},
+		{name: "sha256 of bytes", expr: "sha256", algorithm: "sha256", cached: []byte("hello")},
+		{name: "different value", expr: "sha256", algorithm: "sha256", cached: "hello!", wantErr: true},
+		{name: "different algorithm", expr: "sha256", algorithm: "sha1", cached: "hello", wantErr: true},
+		{name: "unknown algorithm", expr: "sha256", algorithm: "sha512", cached: "hello", wantErr: true},
+		{name: "cached value is not string", expr: "sha256", algorithm: "sha256", cached: 5, wantErr: true},
+		{name: "node is not string", expr: "number", algorithm: "md5", cached: "hello", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("DATA", tt.cached)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldEqualHashOfCachedValue(tt.expr, tt.algorithm, "DATA"); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldEqualHashOfCachedValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseCookieShouldBe(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Header: http.Header{}}
+	s.lastResponse.Header.Add("Set-Cookie", "session=abc; Path=/; Secure; HttpOnly; SameSite=Strict")
+	s.lastResponse.Header.Add("Set-Cookie", "theme=dark; SameSite=Lax")
+
+	tests := []struct {
+		name    string
+		check   func() error
+		wantErr bool
+	}{
+		{name: "secure", check: func() error { return s.TheResponseCookieShouldBeSecure("session") }},
+		{name: "not secure", check: func() error { return s.TheResponseCookieShouldBeSecure("theme") }, wantErr: true},
+		{name: "http only", check: func() error { return s.TheResponseCookieShouldBeHttpOnly("session") }},
+		{name: "not http only", check: func() error { return s.TheResponseCookieShouldBeHttpOnly("theme") }, wantErr: true},
+		{name: "same site strict", check: func() error { return s.TheResponseCookieShouldBeSameSite("session", "Strict") }},
+		{name: "same site lax", check: func() error { return s.TheResponseCookieShouldBeSameSite("theme", "lax") }},
+		{name: "different same site", check: func() error { return s.TheResponseCookieShouldBeSameSite("theme", "None") }, wantErr: true},
+		{name: "missing cookie", check: func() error { return s.TheResponseCookieShouldBeSecure("token") }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check(); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldBeValidURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/users/1" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	body := fmt.Sprintf(`{"self": "%s/users/1", "missing": "%s/users/2", "relative": "/users/1", "invalid": "http://%%zz", "number": 1}`, srv.URL, srv.URL)
+	tests := []struct {
+		expr             string
+		wantErr          bool
+		wantReachableErr bool
+	}{
+		{expr: "self"},
+		{expr: "missing", wantReachableErr: true},
+		{expr: "relative", wantErr: true, wantReachableErr: true},
+		{expr: "invalid", wantErr: true, wantReachableErr: true},
+		{expr: "number", wantErr: true, wantReachableErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.lastResponse = &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+			if err := s.TheJSONNodeShouldBeValidURL(tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeShouldBeValidURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := s.TheJSONNodeShouldBeValidURLAndShouldBeReachable(tt.expr); (err != nil) != tt.wantReachableErr {
+				t.Errorf("TheJSONNodeShouldBeValidURLAndShouldBeReachable() error = %v, wantErr %v", err, tt.wantReachableErr)
+			}
+
+			if err := s.TheJSONNodeShouldBe("self", "string"); err != nil {
+				t.Errorf("last response should not be replaced: %v", err)
+			}
+		})
+	}
+}
+
+func TestApiFeature_IFollowJSONNodeLink(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			_, _ = fmt.Fprintf(w, `{"_links": {"first": "users/1", "second": "%s/api/users/2", "invalid": "http://%%zz", "count": 2}}`, srv.URL)
+		case "/api/users/1":
+			_, _ = w.Write([]byte(`{"id": 1, "_links": {"orders": "/api/users/1/orders"}}`))
+		case "/api/users/2":
+			_, _ = w.Write([]byte(`{"id": 2}`))
+		case "/api/users/1/orders":
+			_, _ = w.Write([]byte(`{"orders": []}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	send := func() {
+		t.Helper()
+		if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+"/api/users", &godog.DocString{Content: `{"body": {}, "headers": {}}`}); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	send()
+	if err := s.IFollowJSONNodeLink("_links.first"); err != nil {
+		t.Fatalf("IFollowJSONNodeLink() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("id", "int", "1"); err != nil {
+		t.Errorf("followed relative link: %v", err)
+	}
+
+	if err := s.IFollowJSONNodeLink("_links.orders"); err != nil {
+		t.Fatalf("IFollowJSONNodeLink() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeSliceOfLength("orders", 0); err != nil {
+		t.Errorf("followed link of followed resource: %v", err)
+	}
+
+	send()
+	if err := s.IFollowJSONNodeLink("_links.second"); err != nil {
+		t.Fatalf("IFollowJSONNodeLink() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValue("id", "int", "2"); err != nil {
+		t.Errorf("followed absolute link: %v", err)
+	}
+
+	send()
+	if err := s.IFollowJSONNodeLink("_links.invalid"); err == nil {
+		t.Errorf("IFollowJSONNodeLink() should fail for invalid URL")
+	}
+
+	if err := s.IFollowJSONNodeLink("_links.count"); err == nil {
+		t.Errorf("IFollowJSONNodeLink() should fail for node which is not string")
+	}
+}
+
+func TestApiFeature_IComputeAndSaveAs(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       float64
+		wantErr    string
+	}{
+		{name: "precedence", expression: "COUNT * 2 + 1", want: 7},
+		{name: "parentheses", expression: "(COUNT + PRICE) * 2", want: 9},
+		{name: "unary minus and division", expression: "-TOTAL / 4 - .5", want: -3},
+		{name: "division by zero", expression: "COUNT / (PRICE - 1.5)", wantErr: "division by zero"},
+		{name: "non numeric reference", expression: "NAME + 1", wantErr: "expected number"},
+		{name: "missing reference", expression: "MISSING + 1", wantErr: ErrPreservedData.Error()},
+		{name: "missing parenthesis", expression: "(COUNT + 1", wantErr: "missing closing parenthesis"},
+		{name: "unexpected character", expression: "COUNT % 2", wantErr: "unexpected"},
+		{name: "empty expression", expression: "", wantErr: "unexpected end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			s.Save("COUNT", 3)
+			s.Save("PRICE", 1.5)
+			s.Save("TOTAL", float64(10))
+			s.Save("NAME", "ivo")
+
+			err := s.IComputeAndSaveAs(tt.expression, "RESULT")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("IComputeAndSaveAs() error = %v, want error containing %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("IComputeAndSaveAs() error = %v", err)
+			}
+
+			if got, _ := s.GetSaved("RESULT"); got != tt.want {
+				t.Errorf("computed value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISetRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISetRequestTimeout("50ms"); err != nil {
+		t.Fatalf("ISetRequestTimeout() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+"/fast", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBe("ok", "bool"); err != nil {
+		t.Errorf("body of response received within timeout should be readable: %v", err)
+	}
+
+	err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+"/slow", request)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ISendRequestToWithBodyAndHeaders() error = %v, expected timeout error", err)
+	}
+
+	s.ResetScenario(false)
+	if err := s.ISetRequestTimeout("-1s"); err == nil {
+		t.Errorf("ISetRequestTimeout() should fail for negative timeout")
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldBeOfValueGreaterThanAndLessThan(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(`{"price": 10.5, "name": "book"}`))}
+
+	if err := s.TheJSONNodeShouldBeOfValueGreaterThan("price", 10); err != nil {
+		t.Errorf("TheJSONNodeShouldBeOfValueGreaterThan() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValueGreaterThan("price", 10.5); err == nil {
+		t.Errorf("TheJSONNodeShouldBeOfValueGreaterThan() should fail for equal value")
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValueLessThan("price", 11); err != nil {
+		t.Errorf("TheJSONNodeShouldBeOfValueLessThan() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValueLessThan("price", 10.5); err == nil {
+		t.Errorf("TheJSONNodeShouldBeOfValueLessThan() should fail for equal value")
+	}
+
+	if err := s.TheJSONNodeShouldBeOfValueGreaterThan("name", 0); !errors.Is(err, ErrJsonNode) {
+		t.Errorf("TheJSONNodeShouldBeOfValueGreaterThan() error = %v, expected %v for non numeric node", err, ErrJsonNode)
+	}
+}
+
+func Test_canonicalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "sorted keys and no white spaces", data: `{ "b": [1, 2], "a": {"d": true, "c": null} }`, want: `{"a":{"c":null,"d":true},"b":[1,2]}`},
+		{name: "numbers", data: `[1.0, -0, 1E2, 0.000001, 1e-7, 1e21, 123456789012345678901, 4.50, 1e+30, 333333333.33333329]`, want: `[1,0,100,0.000001,1e-7,1e+21,123456789012345680000,4.5,1e+30,333333333.3333333]`},
+		{name: "strings", data: `"\u20ac$\u000f\nA'B\"\\\\\"/"`, want: "\"\u20ac$\\u000f\\nA'B\\\"\\\\\\\\\\\"/\""},
+		{name: "keys sorted by UTF-16 code units", data: `{"\u20ac": 1, "\ufb33": 6, "\ud83d\ude00": 2, "\r": 3, "1": 4, "\u00f6": 5}`, want: "{\"\\r\":3,\"1\":4,\"\u00f6\":5,\"\u20ac\":1,\"\U0001f600\":2,\"\ufb33\":6}"},
+		{name: "invalid JSON", data: `{"a":`, wantErr: true},
+		{name: "data after document", data: `{} {}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalJSON([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("canonicalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("canonicalJSON() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheCanonicalResponseShouldEqualCached(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(`{"amount": 10.50, "currency": "EUR"}`))}
+	if err := s.ISaveCanonicalJSONOfResponseAs("CANONICAL"); err != nil {
+		t.Fatalf("ISaveCanonicalJSONOfResponseAs() error = %v", err)
+	}
+
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(`{"currency":"EUR","amount":1.05e1}`))}
+	if err := s.TheCanonicalResponseShouldEqualCached("CANONICAL"); err != nil {
+		t.Errorf("TheCanonicalResponseShouldEqualCached() error = %v", err)
+	}
+
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(`{"currency":"EUR","amount":10.51}`))}
+	if err := s.TheCanonicalResponseShouldEqualCached("CANONICAL"); err == nil {
+		t.Errorf("TheCanonicalResponseShouldEqualCached() should fail for different body")
+	}
+}
+
+func TestApiFeature_ISaveFromTheLastResponseHeaderAs(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Header: http.Header{"Location": []string{"/users/12"}}}
+
+	if err := s.ISaveFromTheLastResponseHeaderAs("location", "LOCATION"); err != nil {
+		t.Fatalf("ISaveFromTheLastResponseHeaderAs() error = %v", err)
+	}
+
+	location, err := s.GetSavedString("LOCATION")
+	if err != nil || location != "/users/12" {
+		t.Errorf("cached header value = %q, error = %v, expected: /users/12", location, err)
+	}
+
+	if err = s.ISaveFromTheLastResponseHeaderAs("X-Request-Id", "REQUEST_ID"); err == nil {
+		t.Errorf("ISaveFromTheLastResponseHeaderAs() should fail for missing header")
+	}
+}
+
+func TestApiFeature_TheResponseHeaderShouldBeSet(t *testing.T) {
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Header: http.Header{"Allow": []string{"GET, HEAD", "options"}}}
+
+	tests := []struct {
+		name      string
+		header    string
+		valuesCSV string
+		wantErr   bool
+	}{
+		{name: "same members in different order and case", header: "Allow", valuesCSV: "OPTIONS,get, head"},
+		{name: "missing member", header: "Allow", valuesCSV: "GET,HEAD,OPTIONS,POST", wantErr: true},
+		{name: "unexpected member", header: "Allow", valuesCSV: "GET,HEAD", wantErr: true},
+		{name: "missing header", header: "Access-Control-Allow-Methods", valuesCSV: "GET", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.TheResponseHeaderShouldBeSet(tt.header, tt.valuesCSV); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseHeaderShouldBeSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISetBasicAuth(t *testing.T) {
+	var authorizations []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("PASSWORD", "secret")
+	if err := s.ISetBasicAuth("admin", "{{.PASSWORD}}"); err != nil {
+		t.Fatalf("ISetBasicAuth() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	s.ResetScenario(false)
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	expected := []string{"Basic YWRtaW46c2VjcmV0", ""}
+	if !reflect.DeepEqual(authorizations, expected) {
+		t.Errorf("Authorization headers = %q, expected: %q", authorizations, expected)
+	}
+}
+
+func TestApiFeature_TheStreamedJSONArrayAtShouldHaveAtLeastElements(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/root":
+			_, _ = w.Write([]byte(`[1, {"a": [2]}, "3"]`))
+		default:
+			_, _ = w.Write([]byte(`{"meta": {"items": [0]}, "data": {"pages": [{"skip": [[1], {"x": 2}]}, {"items": [{"id": 1}, [2, 3], null]}]}}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		arrayPath string
+		n         int
+		wantErr   bool
+	}{
+		{name: "root array", path: "/root", arrayPath: "", n: 3},
+		{name: "root array too short", path: "/root", arrayPath: "", n: 4, wantErr: true},
+		{name: "nested array", path: "/nested", arrayPath: "data.pages[1].items", n: 3},
+		{name: "nested array too short", path: "/nested", arrayPath: "data.pages[1].items", n: 4, wantErr: true},
+		{name: "missing key", path: "/nested", arrayPath: "data.users", n: 1, wantErr: true},
+		{name: "missing index", path: "/nested", arrayPath: "data.pages[2].items", n: 1, wantErr: true},
+		{name: "node is not array", path: "/nested", arrayPath: "data", n: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{}
+			s.ResetScenario(false)
+			if err := s.TheStreamedJSONArrayAtShouldHaveAtLeastElements(srv.URL+tt.path, tt.arrayPath, tt.n); (err != nil) != tt.wantErr {
+				t.Errorf("TheStreamedJSONArrayAtShouldHaveAtLeastElements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheStreamedJSONLinesNodeShouldBeIncreasing_usesScenarioRequestSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "jan" || pass != "secret" || r.URL.Query().Get("page") != "2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte("{\"n\": 1}\n{\"n\": 2}\n"))
+	}))
+	defer srv.Close()
+
+	obs := &mockMetricsObserver{}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.SetMetricsObserver(obs)
+	if err := s.ISetBasicAuth("jan", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ISetFollowingQueryParamsForNextRequest(&godog.DocString{Content: `{"page": "2"}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TheStreamedJSONLinesNodeShouldBeIncreasing(srv.URL, "n", "1s"); err != nil {
+		t.Errorf("TheStreamedJSONLinesNodeShouldBeIncreasing() error = %v", err)
+	}
+
+	if len(obs.started) != 1 || obs.statusCode != http.StatusOK {
+		t.Errorf("metrics observer got requests %v with status %d, expected 1 request", obs.started, obs.statusCode)
+	}
+}
+
+func TestApiFeature_TheStreamedJSONArrayAtShouldHaveAtLeastElements_usesScenarioRequestSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "jan" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(`[1, 2, 3]`))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISetBasicAuth("jan", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TheStreamedJSONArrayAtShouldHaveAtLeastElements(srv.URL, "", 3); err != nil {
+		t.Errorf("TheStreamedJSONArrayAtShouldHaveAtLeastElements() error = %v", err)
+	}
+}
+
+func TestApiFeature_TheJSONNodeShouldBeValidURLAndShouldBeReachable_usesScenarioRequestSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(`{"url": "` + srv.URL + `"}`))}
+	if err := s.TheJSONNodeShouldBeValidURLAndShouldBeReachable("url"); err == nil {
+		t.Errorf("TheJSONNodeShouldBeValidURLAndShouldBeReachable() expected error without Basic Auth")
+	}
+
+	if err := s.ISetBasicAuth("jan", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TheJSONNodeShouldBeValidURLAndShouldBeReachable("url"); err != nil {
+		t.Errorf("TheJSONNodeShouldBeValidURLAndShouldBeReachable() error = %v", err)
+	}
+}
+
+func TestApiFeature_ISetFollowingQueryParamsForNextRequest(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("NAME", "Jan & Anna")
+	if err := s.ISetFollowingQueryParamsForNextRequest(&godog.DocString{Content: `{"name": "{{.NAME}}", "page": "2"}`}); err != nil {
+		t.Fatalf("ISetFollowingQueryParamsForNextRequest() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL+"/users?sort=asc", request); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	expected := []string{"name=Jan+%26+Anna&page=2&sort=asc", "sort=asc"}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Errorf("queries = %q, expected: %q", queries, expected)
+	}
+
+	if err := s.ISetFollowingQueryParamsForNextRequest(&godog.DocString{Content: `{"page": 2}`}); !errors.Is(err, ErrJson) {
+		t.Errorf("ISetFollowingQueryParamsForNextRequest() error = %v, expected %v", err, ErrJson)
+	}
+}
+
+func TestApiFeature_ISendMultipartRequestTo(t *testing.T) {
+	dir := t.TempDir()
+	avatarPath := filepath.Join(dir, "avatar.png")
+	if err := ioutil.WriteFile(avatarPath, []byte("png content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("avatar")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		content, _ := ioutil.ReadAll(file)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"description": r.FormValue("description"),
+			"size":        r.FormValue("size"),
+			"filename":    header.Filename,
+			"content":     string(content),
+			"token":       r.Header.Get("X-Token"),
+			"streamed":    strconv.FormatBool(r.ContentLength == -1),
+		})
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("AVATAR", avatarPath)
+	form := &godog.DocString{Content: `{"body": {"description": "profile", "size": 1000000, "avatar": "file://{{.AVATAR}}"}, "headers": {"X-Token": "abc"}}`}
+	if err := s.ISendMultipartRequestTo(http.MethodPost, srv.URL, form); err != nil {
+		t.Fatalf("ISendMultipartRequestTo() error = %v", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(s.GetLastResponseBody(), &got); err != nil {
+		t.Fatalf("unexpected response %s: %v", s.GetLastResponseBody(), err)
+	}
+
+	expected := map[string]string{"description": "profile", "size": "1000000", "filename": "avatar.png", "content": "png content", "token": "abc", "streamed": "true"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("received form = %v, expected: %v", got, expected)
+	}
+
+	missing := &godog.DocString{Content: `{"body": {"avatar": "file://` + filepath.Join(dir, "missing.png") + `"}, "headers": {}}`}
+	if err := s.ISendMultipartRequestTo(http.MethodPost, srv.URL, missing); err == nil || !strings.Contains(err.Error(), "avatar") {
+		t.Errorf("ISendMultipartRequestTo() error = %v, expected error about missing file of field avatar", err)
+	}
+
+	object := &godog.DocString{Content: `{"body": {"meta": {"a": 1}}, "headers": {}}`}
+	if err := s.ISendMultipartRequestTo(http.MethodPost, srv.URL, object); err == nil || !strings.Contains(err.Error(), "meta") {
+		t.Errorf("ISendMultipartRequestTo() error = %v, expected error about non scalar value of field meta", err)
+	}
+}
+
+func TestApiFeature_TheResponseStatusTextShouldBe(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		code    int
+		text    string
+		wantErr bool
+	}{
+		{name: "equal text", status: "404 Not Found", code: 404, text: "Not Found"},
+		{name: "different case", status: "200 OK", code: 200, text: "ok"},
+		{name: "rewritten by proxy", status: "200 Alright", code: 200, text: "OK", wantErr: true},
+		{name: "missing text", status: "200", code: 200, text: "OK", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{lastResponse: &http.Response{Status: tt.status, StatusCode: tt.code}}
+			if err := s.TheResponseStatusTextShouldBe(tt.text); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseStatusTextShouldBe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_SetClock(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.SetClock(func() time.Time { return now })
+	s.lastResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(`{"createdAt": "2021-06-01T11:59:00Z"}`))}
+
+	if err := s.TheJSONNodeDateShouldBeWithinOfNow("createdAt", "2m"); err != nil {
+		t.Errorf("TheJSONNodeDateShouldBeWithinOfNow() error = %v", err)
+	}
+
+	if err := s.TheJSONNodeDateShouldBeWithinOfNow("createdAt", "30s"); err == nil {
+		t.Errorf("TheJSONNodeDateShouldBeWithinOfNow() should fail for date older than interval")
+	}
+
+	if err := s.IStartFlowTimer(); err != nil {
+		t.Fatalf("IStartFlowTimer() error = %v", err)
+	}
+
+	now = now.Add(5 * time.Second)
+	if err := s.TheFlowShouldHaveTakenLessThan("5s"); err == nil {
+		t.Errorf("TheFlowShouldHaveTakenLessThan() should fail when clock moved by 5s")
+	}
+
+	if err := s.TheFlowShouldHaveTakenLessThan("6s"); err != nil {
+		t.Errorf("TheFlowShouldHaveTakenLessThan() error = %v", err)
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeaders_detectsContentType(t *testing.T) {
+	var contentTypes, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.IEnableContentTypeAutoDetection(); err != nil {
+		t.Fatalf("IEnableContentTypeAutoDetection() error = %v", err)
+	}
+
+	requests := []string{
+		`{"body": "<user><name>Jan</name></user>", "headers": {}}`,
+		`{"body": "plain text", "headers": {}}`,
+		`{"body": {"name": "Jan"}, "headers": {}}`,
+		`{"body": "<user/>", "headers": {"Content-Type": "text/xml"}}`,
+	}
+	for _, request := range requests {
+		if err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, srv.URL, &godog.DocString{Content: request}); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	expectedContentTypes := []string{"application/xml", "text/plain; charset=utf-8", "application/json", "text/xml"}
+	if !reflect.DeepEqual(contentTypes, expectedContentTypes) {
+		t.Errorf("received Content-Type headers = %q, expected: %q", contentTypes, expectedContentTypes)
+	}
+
+	expectedBodies := []string{"<user><name>Jan</name></user>", "plain text", `{"name":"Jan"}`, "<user/>"}
+	if !reflect.DeepEqual(bodies, expectedBodies) {
+		t.Errorf("received bodies = %q, expected: %q", bodies, expectedBodies)
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeadersAndDeadlineFrom_readsStreamedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		_, _ = w.Write([]byte(`{"items": [`))
+		for i := 0; i < 5; i++ {
+			flusher.Flush()
+			time.Sleep(20 * time.Millisecond)
+			if i > 0 {
+				_, _ = w.Write([]byte(","))
+			}
+			_, _ = w.Write([]byte(`"` + strings.Repeat("x", 10000) + `"`))
+		}
+		_, _ = w.Write([]byte(`]}`))
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("DEADLINE", time.Now().Add(5*time.Second))
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	if err := s.ISendRequestToWithBodyAndHeadersAndDeadlineFrom(http.MethodGet, srv.URL, "DEADLINE", request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeadersAndDeadlineFrom() error = %v", err)
+	}
+
+	if err := s.TheResponseShouldBeIn(typeJSON); err != nil {
+		t.Errorf("TheResponseShouldBeIn() error = %v, body has %d bytes", err, len(s.GetLastResponseBody()))
+	}
+
+	if err := s.TheJSONNodeShouldBeSliceOfLength("items", 5); err != nil {
+		t.Errorf("TheJSONNodeShouldBeSliceOfLength() error = %v", err)
+	}
+}
+
+func TestApiFeature_ISendMultipartRequestTo_setsHost(t *testing.T) {
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	form := &godog.DocString{Content: `{"body": {"name": "avatar"}, "headers": {"host": "api.example.com"}}`}
+	if err := s.ISendMultipartRequestTo(http.MethodPost, srv.URL, form); err != nil {
+		t.Fatalf("ISendMultipartRequestTo() error = %v", err)
+	}
+
+	if host != "api.example.com" {
+		t.Errorf("received Host = %s, expected: api.example.com", host)
+	}
+}
+
+func TestApiFeature_GetLastResponseAsJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "object", body: `{"name": "Jan", "age": 30, "tags": ["a"]}`, want: map[string]interface{}{"name": "Jan", "age": float64(30), "tags": []interface{}{"a"}}},
+		{name: "not JSON", body: `<user/>`, wantErr: true},
+		{name: "top-level array", body: `[{"name": "Jan"}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(tt.body))}}
+			got, err := s.GetLastResponseAsJSON()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetLastResponseAsJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrJson) {
+				t.Errorf("GetLastResponseAsJSON() error = %v, expected to wrap %v", err, ErrJson)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLastResponseAsJSON() got = %v, want %v", got, tt.want)
+			}
+
+			if body := string(s.GetLastResponseBody()); body != tt.body {
+				t.Errorf("body read after GetLastResponseAsJSON() = %s, want %s", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestApiFeature_GetSavedTypedValues(t *testing.T) {
+	createdAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("NAME", "Jan")
+	s.Save("AGE", 30)
+	s.Save("CREATED_AT", createdAt)
+
+	tests := []struct {
+		name    string
+		get     func(key string) (interface{}, error)
+		key     string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "string", get: func(key string) (interface{}, error) { return s.GetSavedString(key) }, key: "NAME", want: "Jan"},
+		{name: "string of wrong type", get: func(key string) (interface{}, error) { return s.GetSavedString(key) }, key: "AGE", want: "", wantErr: true},
+		{name: "missing string", get: func(key string) (interface{}, error) { return s.GetSavedString(key) }, key: "MISSING", want: "", wantErr: true},
+		{name: "int", get: func(key string) (interface{}, error) { return s.GetSavedInt(key) }, key: "AGE", want: 30},
+		{name: "int of wrong type", get: func(key string) (interface{}, error) { return s.GetSavedInt(key) }, key: "NAME", want: 0, wantErr: true},
+		{name: "missing int", get: func(key string) (interface{}, error) { return s.GetSavedInt(key) }, key: "MISSING", want: 0, wantErr: true},
+		{name: "time", get: func(key string) (interface{}, error) { return s.GetSavedTime(key) }, key: "CREATED_AT", want: createdAt},
+		{name: "time of wrong type", get: func(key string) (interface{}, error) { return s.GetSavedTime(key) }, key: "NAME", want: time.Time{}, wantErr: true},
+		{name: "missing time", get: func(key string) (interface{}, error) { return s.GetSavedTime(key) }, key: "MISSING", want: time.Time{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrPreservedData) {
+				t.Errorf("error = %v, expected to wrap %v", err, ErrPreservedData)
+			}
+
+			if got != tt.want {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseBodyShouldContainSubstringTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		substring string
+		count     int
+		wantErr   bool
+	}{
+		{name: "exact count", body: `{"errors": ["invalid", "invalid", "missing"]}`, substring: "invalid", count: 2},
+		{name: "wrong count", body: `{"errors": ["invalid", "invalid", "missing"]}`, substring: "invalid", count: 3, wantErr: true},
+		{name: "zero occurrences", body: `{"errors": []}`, substring: "invalid", count: 0},
+		{name: "zero occurrences expected but found", body: `{"errors": ["invalid"]}`, substring: "invalid", count: 0, wantErr: true},
+		{name: "overlapping matches are counted once", body: "aaaa", substring: "aa", count: 2},
+		{name: "overlapping matches are not counted twice", body: "aaa", substring: "aa", count: 2, wantErr: true},
+		{name: "empty substring", body: "abc", substring: "", count: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(tt.body))}}
+			if err := s.TheResponseBodyShouldContainSubstringTimes(tt.substring, tt.count); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseBodyShouldContainSubstringTimes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_ISendRequestToWithBodyAndHeaders_sendsStringBodyAsJSONWithoutDetection(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, _ := ioutil.ReadAll(r.Body)
+		body = string(bodyBytes)
+	}))
+	defer srv.Close()
+
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodPost, srv.URL, &godog.DocString{Content: `{"body": "abc", "headers": {}}`}); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if body != `"abc"` {
+		t.Errorf("received body = %s, expected JSON string \"abc\"", body)
+	}
+}
+
+func TestApiFeature_ISetBasicAuth_withAWSSigV4(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.SetClock(func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) })
+	if err := s.ISetAWSSigV4Signing("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service"); err != nil {
+		t.Fatalf("ISetAWSSigV4Signing() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+	signedWithoutBasicAuth := authorization
+
+	if err := s.ISetBasicAuth("admin", "secret"); err != nil {
+		t.Fatalf("ISetBasicAuth() error = %v", err)
+	}
+
+	if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+		t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+	}
+
+	if !strings.Contains(authorization, "SignedHeaders=host;x-amz-date,") {
+		t.Errorf("Authorization = %s, expected only host and x-amz-date headers to be signed", authorization)
+	}
+
+	if authorization != signedWithoutBasicAuth {
+		t.Errorf("Authorization with basic auth = %s, expected the same signature as without it: %s", authorization, signedWithoutBasicAuth)
+	}
+
+	if strings.Contains(s.lastCanonicalRequest, "authorization") {
+		t.Errorf("canonical request should not contain Authorization header: %s", s.lastCanonicalRequest)
+	}
+}
+
+func TestApiFeature_ISetColorizedDebug(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    string
+		structured bool
+		noColor    string
+		wantColors bool
+		wantErr    bool
+	}{
+		{name: "colors enabled", enabled: "true", wantColors: true},
+		{name: "colors disabled", enabled: "false"},
+		{name: "NO_COLOR set", enabled: "true", noColor: "1"},
+		{name: "structured debug", enabled: "true", structured: true},
+		{name: "invalid value", enabled: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			var out bytes.Buffer
+			s := &Scenario{}
+			s.ResetScenario(true)
+			s.SetDebugOutput(&out)
+			s.structuredDebug = tt.structured
+			if err := s.ISetColorizedDebug(tt.enabled); (err != nil) != tt.wantErr {
+				t.Fatalf("ISetColorizedDebug() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			s.debugDiff("a\nb", "a\nc")
+			if !strings.Contains(out.String(), "b") || !strings.Contains(out.String(), "c") {
+				t.Errorf("debug output %q does not contain diff", out.String())
+			}
+
+			if hasColors := strings.Contains(out.String(), "\033["); hasColors != tt.wantColors {
+				t.Errorf("debug output %q has ANSI colors: %v, expected: %v", out.String(), hasColors, tt.wantColors)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheResponseBodyShouldContainSubstringAtLeastTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		substring string
+		count     int
+		wantErr   bool
+	}{
+		{name: "exact count", body: `{"errors": ["invalid", "invalid", "missing"]}`, substring: "invalid", count: 2},
+		{name: "more than count", body: `{"errors": ["invalid", "invalid", "missing"]}`, substring: "invalid", count: 1},
+		{name: "less than count", body: `{"errors": ["invalid", "invalid", "missing"]}`, substring: "invalid", count: 3, wantErr: true},
+		{name: "zero count without occurrences", body: `{"errors": []}`, substring: "invalid", count: 0},
+		{name: "zero count with occurrences", body: `{"errors": ["invalid"]}`, substring: "invalid", count: 0},
+		{name: "overlapping matches are counted once", body: "aaaa", substring: "aa", count: 2},
+		{name: "overlapping matches are not counted twice", body: "aaa", substring: "aa", count: 2, wantErr: true},
+		{name: "empty substring", body: "abc", substring: "", count: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(tt.body))}}
+			if err := s.TheResponseBodyShouldContainSubstringAtLeastTimes(tt.substring, tt.count); (err != nil) != tt.wantErr {
+				t.Errorf("TheResponseBodyShouldContainSubstringAtLeastTimes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_TheJSONNodeStringShouldHaveLengthBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		min     int
+		max     int
+		wantErr bool
+	}{
+		{name: "length within bounds", body: `{"name": "abcd"}`, min: 3, max: 5},
+		{name: "length equal to min", body: `{"name": "abc"}`, min: 3, max: 5},
+		{name: "length equal to max", body: `{"name": "abcde"}`, min: 3, max: 5},
+		{name: "length below min", body: `{"name": "ab"}`, min: 3, max: 5, wantErr: true},
+		{name: "length above max", body: `{"name": "abcdef"}`, min: 3, max: 5, wantErr: true},
+		{name: "multibyte runes are counted once", body: `{"name": "żółć"}`, min: 4, max: 4},
+		{name: "node is not string", body: `{"name": 1234}`, min: 0, max: 10, wantErr: true},
+		{name: "min greater than max", body: `{"name": "abcd"}`, min: 5, max: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{lastResponse: &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(tt.body))}}
+			if err := s.TheJSONNodeStringShouldHaveLengthBetween("name", tt.min, tt.max); (err != nil) != tt.wantErr {
+				t.Errorf("TheJSONNodeStringShouldHaveLengthBetween() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiFeature_IRemoveHeaderFromNextRequest(t *testing.T) {
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("X-Token"))
+		if _, _, ok := r.BasicAuth(); !ok {
+			tokens = append(tokens, "no auth")
+		}
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {"X-Token": "abc"}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	if err := s.ISetBasicAuth("jan", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"x-token", "Authorization", "X-Missing"} {
+		if err := s.IRemoveHeaderFromNextRequest(name); err != nil {
+			t.Fatalf("IRemoveHeaderFromNextRequest() error = %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	expected := []string{"", "no auth", "abc"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("received headers = %q, expected: %q", tokens, expected)
+	}
+}
+
+func TestApiFeature_ISetCookieForNextRequest(t *testing.T) {
+	var cookies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookies = append(cookies, r.Header.Get("Cookie"))
+	}))
+	defer srv.Close()
+
+	request := &godog.DocString{Content: `{"body": {}, "headers": {}}`}
+	s := &Scenario{}
+	s.ResetScenario(false)
+	s.Save("SESSION", "abc123")
+	if err := s.ISetCookieForNextRequest("session", "{{.SESSION}}"); err != nil {
+		t.Fatalf("ISetCookieForNextRequest() error = %v", err)
+	}
+
+	if err := s.ISetCookieForNextRequest("lang", "pl"); err != nil {
+		t.Fatalf("ISetCookieForNextRequest() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.ISendRequestToWithBodyAndHeaders(http.MethodGet, srv.URL, request); err != nil {
+			t.Fatalf("ISendRequestToWithBodyAndHeaders() error = %v", err)
+		}
+	}
+
+	expected := []string{"session=abc123; lang=pl", ""}
+	if !reflect.DeepEqual(cookies, expected) {
+		t.Errorf("received cookies = %q, expected: %q", cookies, expected)
+	}
+
+	if err := s.ISetCookieForNextRequest("", "abc"); err == nil {
+		t.Errorf("ISetCookieForNextRequest() expected error for empty name")
+	}
+}
@@ -0,0 +1,31 @@
+package gdutils
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//Guard wraps step function, so it does nothing and succeeds while guarded block started by
+//ISkipGuardedStepsIfCachedValueIsFalse is active. Returned function has the same signature as step,
+//so it may be registered in godog, e.g. ctx.Step(`^...$`, s.Guard(s.TheJSONNodeShouldBe)).
+//Guard panics if step is not function returning only error, like godog does for invalid step definitions.
+func (s *Scenario) Guard(step interface{}) interface{} {
+	stepValue := reflect.ValueOf(step)
+	stepType := stepValue.Type()
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if stepType.Kind() != reflect.Func || stepType.NumOut() != 1 || stepType.Out(0) != errorType {
+		panic(fmt.Sprintf("guarded step should be function returning only error, got %s", stepType))
+	}
+
+	return reflect.MakeFunc(stepType, func(args []reflect.Value) []reflect.Value {
+		if s.skipGuarded {
+			return []reflect.Value{reflect.Zero(errorType)}
+		}
+
+		if stepType.IsVariadic() {
+			return stepValue.CallSlice(args)
+		}
+
+		return stepValue.Call(args)
+	}).Interface()
+}
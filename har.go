@@ -0,0 +1,99 @@
+package gdutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+//harFile represents parts of HTTP Archive (HAR) file needed to replay recorded responses.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+//harEntry represents single recorded request and its response.
+type harEntry struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+//harReplayTransport is http.RoundTripper which does not send requests, it responds with responses recorded in HAR file.
+//Requests are matched with recorded ones by method and URL.
+type harReplayTransport struct {
+	entries map[string]harEntry
+}
+
+//newHARReplayTransport creates harReplayTransport from HAR file, later entries override earlier ones with the same method and URL
+func newHARReplayTransport(har harFile) (*harReplayTransport, error) {
+	entries := make(map[string]harEntry, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		if entry.Response.Content.Encoding != "" && entry.Response.Content.Encoding != "base64" {
+			return nil, fmt.Errorf("HAR entry %d has unsupported content encoding %s", i, entry.Response.Content.Encoding)
+		}
+
+		entries[harEntryKey(entry.Request.Method, entry.Request.URL)] = entry
+	}
+
+	return &harReplayTransport{entries: entries}, nil
+}
+
+//RoundTrip returns response recorded for req, req is not sent
+func (t *harReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	entry, ok := t.entries[harEntryKey(req.Method, req.URL.String())]
+	if !ok {
+		return nil, fmt.Errorf("HAR file has no recorded response for %s %s", req.Method, req.URL)
+	}
+
+	body := []byte(entry.Response.Content.Text)
+	if entry.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode recorded response for %s %s: %w", req.Method, req.URL, err)
+		}
+
+		body = decoded
+	}
+
+	header := http.Header{}
+	for _, h := range entry.Response.Headers {
+		header.Add(h.Name, h.Value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Response.Status, http.StatusText(entry.Response.Status)),
+		StatusCode:    entry.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+//harEntryKey returns key matching requests with recorded HAR entries
+func harEntryKey(method, url string) string {
+	return method + " " + url
+}
@@ -2,12 +2,37 @@ package gdutils
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
+
+	"github.com/cucumber/godog"
+	"github.com/pawelWritesCode/qjson"
 )
 
 const (
@@ -20,6 +45,12 @@ const (
 		"ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 )
 
+//floatEpsilon is tolerance used when comparing computed float values
+const floatEpsilon = 1e-9
+
+//maxIncludeDepth is maximal nesting of include template function calls
+const maxIncludeDepth = 10
+
 var seededRand *rand.Rand = rand.New(
 	rand.NewSource(time.Now().UnixNano()))
 
@@ -27,11 +58,55 @@ var seededRand *rand.Rand = rand.New(
 //between two brackets {{ }} preceded with dot, for example: {{.NAME}}
 //and replace them with corresponding preserved values, if they are previously cache.
 //
+//Template may also use include function, for example: {{ include "file://path/to/file.json" }}
+//which inlines content of given file, content of file is templated as well.
+//
 //returns input string with replaced values.
 func (s *Scenario) replaceTemplatedValue(inputString string) (string, error) {
-	templ := template.Must(template.New("abc").Parse(inputString))
+	return s.replaceTemplatedValueAtDepth(inputString, 0)
+}
+
+//replaceTemplatedValueAtDepth replaces template values in inputString,
+//depth indices how many include function calls led to inputString
+func (s *Scenario) replaceTemplatedValueAtDepth(inputString string, depth int) (string, error) {
+	funcs := template.FuncMap{
+		"include": func(reference string) (string, error) {
+			if depth >= maxIncludeDepth {
+				return "", fmt.Errorf("could not include %s, includes are nested deeper than %d, probably recursive include", reference, maxIncludeDepth)
+			}
+
+			content, err := ioutil.ReadFile(strings.TrimPrefix(reference, "file://"))
+			if err != nil {
+				return "", err
+			}
+
+			return s.replaceTemplatedValueAtDepth(string(content), depth+1)
+		},
+		"seq": seq,
+		"httpDate": func(date interface{}) (string, error) {
+			switch typedDate := date.(type) {
+			case time.Time:
+				return typedDate.UTC().Format(http.TimeFormat), nil
+			case string:
+				parsedDate, err := parseDate(typedDate, s.dateLayouts)
+				if err != nil {
+					return "", err
+				}
+
+				return parsedDate.UTC().Format(http.TimeFormat), nil
+			default:
+				return "", fmt.Errorf("httpDate argument is %T, expected time.Time or string", date)
+			}
+		},
+	}
+
+	templ, err := template.New("abc").Funcs(funcs).Parse(inputString)
+	if err != nil {
+		return "", err
+	}
+
 	var buff bytes.Buffer
-	err := templ.Execute(&buff, s.cache)
+	err = templ.Execute(&buff, s.cache)
 	if err != nil {
 		return "", err
 	}
@@ -39,6 +114,485 @@ func (s *Scenario) replaceTemplatedValue(inputString string) (string, error) {
 	return buff.String(), nil
 }
 
+//seq returns slice of n consecutive integers starting from 0, it allows to build arrays in templates with range action
+func seq(n int) ([]int, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("seq length should not be negative, got %d", n)
+	}
+
+	sequence := make([]int, n)
+	for i := range sequence {
+		sequence[i] = i
+	}
+
+	return sequence, nil
+}
+
+//buildRequest creates HTTP request from provided method, url template and body template.
+//Argument bodyTemplate should be slice of bytes marshallable on bodyHeaders struct
+func (s *Scenario) buildRequest(method, urlTemplate string, bodyTemplate *godog.DocString) (*http.Request, error) {
+	input, err := s.replaceTemplatedValue(bodyTemplate.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.replaceTemplatedValue(urlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyAndHeaders bodyHeaders
+	err = json.Unmarshal([]byte(input), &bodyAndHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	//with Content-Type auto detection, string body is sent as is, e.g. XML or plain text, other bodies are sent as JSON
+	var reqBody []byte
+	if rawBody, ok := bodyAndHeaders.Body.(string); ok && s.autoContentType {
+		reqBody = []byte(rawBody)
+	} else if reqBody, err = json.Marshal(bodyAndHeaders.Body); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+
+	setRequestHeaders(req, bodyAndHeaders.Headers)
+
+	if s.autoContentType && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", detectContentType(reqBody))
+	}
+
+	return req, nil
+}
+
+//buildMultipartRequest builds HTTP request with multipart/form-data body from templated bodyTemplate,
+//which should be marshallable on bodyHeaders struct with body being JSON object of form fields.
+//Fields with values prefixed with file:// are sent as files read from given path, other fields are sent as form values
+func (s *Scenario) buildMultipartRequest(method, urlTemplate string, bodyTemplate *godog.DocString) (*http.Request, error) {
+	input, err := s.replaceTemplatedValue(bodyTemplate.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.replaceTemplatedValue(urlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyAndHeaders bodyHeaders
+	if err = json.Unmarshal([]byte(input), &bodyAndHeaders); err != nil {
+		return nil, err
+	}
+
+	fields, ok := bodyAndHeaders.Body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("multipart form body should be JSON object, got %T", bodyAndHeaders.Body)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]multipartPart, 0, len(names))
+	for _, name := range names {
+		part, err := newMultipartPart(name, fields[name])
+		if err != nil {
+			closeMultipartParts(parts)
+			return nil, err
+		}
+
+		parts = append(parts, part)
+	}
+
+	//form is written to pipe while request is being sent, so files are streamed instead of being read into memory
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipartParts(writer, parts))
+	}()
+
+	req, err := http.NewRequest(method, url, pr)
+	if err != nil {
+		_ = pr.Close()
+		return nil, err
+	}
+
+	setRequestHeaders(req, bodyAndHeaders.Headers)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req, nil
+}
+
+//setRequestHeaders sets headers on req, Host header is set as req.Host
+func setRequestHeaders(req *http.Request, headers map[string]string) {
+	for headerName, headerValue := range headers {
+		//net/http ignores Host header, host sent to server is taken from request Host field
+		if http.CanonicalHeaderKey(headerName) == "Host" {
+			req.Host = headerValue
+			continue
+		}
+
+		req.Header.Set(headerName, headerValue)
+	}
+}
+
+//multipartPart is single field of multipart form, file is not nil for fields sent as content of file
+type multipartPart struct {
+	name  string
+	value string
+	file  *os.File
+}
+
+//newMultipartPart returns multipart form part of field name with value, string values prefixed with file://
+//reference files which are opened, numbers and booleans are formatted, other values are not supported
+func newMultipartPart(name string, value interface{}) (multipartPart, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, "file://") {
+			return multipartPart{name: name, value: v}, nil
+		}
+
+		file, err := os.Open(strings.TrimPrefix(v, "file://"))
+		if err != nil {
+			return multipartPart{}, fmt.Errorf("could not attach file to form field %s: %w", name, err)
+		}
+
+		return multipartPart{name: name, file: file}, nil
+	case float64:
+		return multipartPart{name: name, value: strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case bool:
+		return multipartPart{name: name, value: strconv.FormatBool(v)}, nil
+	default:
+		return multipartPart{}, fmt.Errorf("form field %s should be string, number or boolean, got %T", name, value)
+	}
+}
+
+//writeMultipartParts writes parts to writer and closes it, files of parts are closed afterwards
+func writeMultipartParts(writer *multipart.Writer, parts []multipartPart) error {
+	defer closeMultipartParts(parts)
+
+	for _, part := range parts {
+		if part.file == nil {
+			if err := writer.WriteField(part.name, part.value); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		w, err := writer.CreateFormFile(part.name, filepath.Base(part.file.Name()))
+		if err != nil {
+			return err
+		}
+
+		if _, err = io.Copy(w, part.file); err != nil {
+			return fmt.Errorf("could not attach file to form field %s: %w", part.name, err)
+		}
+	}
+
+	return writer.Close()
+}
+
+//closeMultipartParts closes files of parts
+func closeMultipartParts(parts []multipartPart) {
+	for _, part := range parts {
+		if part.file != nil {
+			_ = part.file.Close()
+		}
+	}
+}
+
+//sendRequest sends provided HTTP request and preserves its response as last response
+func (s *Scenario) sendRequest(req *http.Request) error {
+	resp, err := s.doRequest(req)
+	if err != nil {
+		return err
+	}
+
+	//body is buffered before request context with deadline is cancelled, so it remains readable
+	if _, hasDeadline := resp.Request.Context().Deadline(); s.maxResponseBodySize > 0 || s.metricsObserver != nil || hasDeadline {
+		if err = s.bufferResponseBody(resp); err != nil {
+			return s.wrapRequestTimeout(resp.Request, err)
+		}
+	}
+
+	if s.lastResponse != nil && s.lastResponse.Body != nil {
+		//body of replaced response is buffered, so it remains readable after its connection is reused
+		_ = s.GetLastResponseBody()
+		s.previousResponse = s.lastResponse
+	}
+
+	s.lastResponse = resp
+
+	//err = s.saveLastResponseCredentials(resp)
+	if s.isDebug {
+		s.debugResponse()
+	}
+
+	return err
+}
+
+//doRequest sends provided HTTP request the way every request of scenario is sent: through transport of next request,
+//with next request query params, Basic Auth, AWS SigV4 signature, request timeout, debug and metrics.
+//Response body is not read, caller should close it, what releases request timeout and notifies metrics observer
+func (s *Scenario) doRequest(req *http.Request) (*http.Response, error) {
+	client := &http.Client{Transport: s.roundTripper}
+	if s.cannedResponse != nil {
+		client.Transport = *s.cannedResponse
+		s.cannedResponse = nil
+	} else if s.harReplay != nil {
+		client.Transport = s.harReplay
+	} else if s.dryRunResponseBody != nil {
+		client.Transport = dryRunTransport{statusCode: http.StatusOK, body: s.dryRunResponseBody}
+	}
+
+	if s.nextQueryParams != nil {
+		query := req.URL.Query()
+		for name, values := range s.nextQueryParams {
+			for _, value := range values {
+				query.Add(name, value)
+			}
+		}
+
+		req.URL.RawQuery = query.Encode()
+		s.nextQueryParams = nil
+	}
+
+	if s.basicAuth != nil {
+		req.SetBasicAuth(s.basicAuth[0], s.basicAuth[1])
+	}
+
+	for _, cookie := range s.nextCookies {
+		req.AddCookie(cookie)
+	}
+	s.nextCookies = nil
+
+	for _, name := range s.nextRemovedHeaders {
+		req.Header.Del(name)
+		if strings.EqualFold(name, "Host") {
+			req.Host = ""
+		}
+	}
+	s.nextRemovedHeaders = nil
+
+	if s.awsSigV4 != nil {
+		canonicalRequest, err := signAWSSigV4(req, *s.awsSigV4, s.now())
+		if err != nil {
+			return nil, err
+		}
+
+		s.lastCanonicalRequest = canonicalRequest
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if s.requestTimeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), s.requestTimeout)
+		req = req.WithContext(ctx)
+	}
+
+	if s.isDebug {
+		s.debugRequest(req)
+	}
+
+	if s.metricsObserver != nil {
+		s.metricsObserver.RequestStarted(req)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, s.wrapRequestTimeout(req, err)
+	}
+
+	resp.Body = &observedBody{ReadCloser: resp.Body, onClose: func(read int) {
+		cancel()
+		if s.metricsObserver != nil {
+			s.metricsObserver.ResponseReceived(req, resp.StatusCode, time.Since(start), read)
+		}
+	}}
+
+	return resp, nil
+}
+
+//observedBody is response body which counts read bytes and calls onClose with their number when it is closed first time
+type observedBody struct {
+	io.ReadCloser
+	read    int
+	onClose func(read int)
+}
+
+//Read reads from underlying body and counts read bytes
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += n
+
+	return n, err
+}
+
+//Close closes underlying body and calls onClose once
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.onClose != nil {
+		b.onClose(b.read)
+		b.onClose = nil
+	}
+
+	return err
+}
+
+//dryRunTransport is http.RoundTripper which does not send requests, it responds with its status code, headers and body instead
+type dryRunTransport struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+//RoundTrip returns response with status code, headers and body of transport, req is not sent
+func (t dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	header := t.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", t.statusCode, http.StatusText(t.statusCode)),
+		StatusCode:    t.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(t.body)),
+		ContentLength: int64(len(t.body)),
+		Request:       req,
+	}, nil
+}
+
+//originalRequest returns first request of redirect chain ended with req
+func originalRequest(req *http.Request) *http.Request {
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+
+	return req
+}
+
+//isNetworkError checks whether err is network error of round trip, e.g. refused connection or timeout.
+//http.Client wraps every error in *url.Error, so error it wraps is checked, errors like unsupported protocol scheme are not network errors
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+
+	var netErr net.Error
+
+	return errors.As(urlErr.Err, &netErr)
+}
+
+//wrapRequestTimeout wraps err with information about request timeout, if context of req reached its deadline
+func (s *Scenario) wrapRequestTimeout(req *http.Request, err error) error {
+	if s.requestTimeout > 0 && errors.Is(req.Context().Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("request %s %s timed out after %s: %w", req.Method, req.URL, s.requestTimeout, err)
+	}
+
+	return err
+}
+
+//bufferResponseBody reads body of resp into memory, so connection may be released, e.g. when request context ends.
+//If maxResponseBodySize is set, at most maxResponseBodySize bytes are read and error is returned if body is larger than limit.
+func (s *Scenario) bufferResponseBody(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	if s.maxResponseBodySize > 0 {
+		reader = io.LimitReader(resp.Body, s.maxResponseBodySize+1)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	if s.maxResponseBodySize > 0 && int64(len(bodyBytes)) > s.maxResponseBodySize {
+		return fmt.Errorf("response body of %s %s exceeds limit of %d bytes", resp.Request.Method, resp.Request.URL, s.maxResponseBodySize)
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	return nil
+}
+
+//printLastResponseBody writes last response body to w, JSON body is indented
+func (s *Scenario) printLastResponseBody(w io.Writer) {
+	var tmp map[string]interface{}
+	err := json.Unmarshal(s.GetLastResponseBody(), &tmp)
+
+	if err != nil {
+		fmt.Fprintln(w, string(s.GetLastResponseBody()))
+		return
+	}
+
+	indentedRespBody, err := json.MarshalIndent(tmp, "", "\t")
+
+	if err != nil {
+		fmt.Fprintln(w, string(s.GetLastResponseBody()))
+		return
+	}
+
+	fmt.Fprintln(w, string(indentedRespBody))
+}
+
+//now returns current time from clock set by SetClock, or time.Now if it is not set
+func (s *Scenario) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+
+	return s.clock()
+}
+
+//withJitter returns d changed by random jitter set by ISetWaitJitter
+func (s *Scenario) withJitter(d time.Duration) time.Duration {
+	if s.waitJitterPercent == 0 {
+		return d
+	}
+
+	r := s.random
+	if r == nil {
+		r = seededRand
+	}
+
+	//j is random number from range [-1, 1)
+	j := r.Float64()*2 - 1
+
+	return d + time.Duration(float64(d)*j*float64(s.waitJitterPercent)/100)
+}
+
+//newUUID returns random version 4 UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
 //stringWithCharset returns random string of given length.
 //Argument length indices length of output string.
 //Argument charset indices input charset from which output string will be composed
@@ -72,6 +626,544 @@ func valueIsNil(v reflect.Value) bool {
 	return false
 }
 
+//valueIsOfType checks whether provided value is of given goType
+//goType may be one of: nil, string, int, float, bool, map, slice
+func valueIsOfType(iValue interface{}, goType string) (bool, error) {
+	v := reflect.ValueOf(iValue)
+	switch goType {
+	case "nil":
+		return !v.IsValid() || valueIsNil(v), nil
+	case "string":
+		return v.Kind() == reflect.String, nil
+	case "int":
+		if v.Kind() == reflect.Int64 || v.Kind() == reflect.Int32 || v.Kind() == reflect.Int16 ||
+			v.Kind() == reflect.Int8 || v.Kind() == reflect.Int || v.Kind() == reflect.Uint ||
+			v.Kind() == reflect.Uint8 || v.Kind() == reflect.Uint16 || v.Kind() == reflect.Uint32 ||
+			v.Kind() == reflect.Uint64 {
+			return true, nil
+		}
+
+		if v.Kind() == reflect.Float64 {
+			_, frac := math.Modf(v.Float())
+			return frac == 0, nil
+		}
+
+		return false, nil
+	case "float":
+		if v.Kind() == reflect.Float64 || v.Kind() == reflect.Float32 {
+			_, frac := math.Modf(v.Float())
+			return frac != 0, nil
+		}
+
+		return false, nil
+	case "bool":
+		return v.Kind() == reflect.Bool, nil
+	case "map":
+		return v.Kind() == reflect.Map, nil
+	case "slice":
+		return v.Kind() == reflect.Slice, nil
+	default:
+		return false, fmt.Errorf("%s is unknown type for this step", goType)
+	}
+}
+
+//hexHash returns hex encoded hash of data computed with algorithm, which may be one of: md5, sha1, sha256
+func hexHash(algorithm string, data []byte) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %s, available algorithms: md5, sha1, sha256", algorithm)
+	}
+
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//decodeBase64 decodes value encoded with standard or URL-safe base64 alphabet, with or without padding
+func decodeBase64(value string) ([]byte, error) {
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		var decoded []byte
+		if decoded, err = encoding.DecodeString(value); err == nil {
+			return decoded, nil
+		}
+	}
+
+	return nil, err
+}
+
+//isTruthy tells whether unmarshalled JSON value is truthy.
+//Values nil, false, 0, empty string, empty slice and empty map are falsy, all other values are truthy
+func isTruthy(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return typedValue
+	case float64:
+		return typedValue != 0
+	case string:
+		return typedValue != ""
+	case []interface{}:
+		return len(typedValue) > 0
+	case map[string]interface{}:
+		return len(typedValue) > 0
+	default:
+		return true
+	}
+}
+
+//castValue converts provided value to given goType
+//goType may be one of: string, int, float, bool
+func castValue(iValue interface{}, goType string) (interface{}, error) {
+	errImpossible := fmt.Errorf("could not cast %v of type %T to %s", iValue, iValue, goType)
+
+	switch goType {
+	case "string":
+		switch v := iValue.(type) {
+		case string:
+			return v, nil
+		case int:
+			return strconv.Itoa(v), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	case "int":
+		switch v := iValue.(type) {
+		case int:
+			return v, nil
+		case float64:
+			intPart, frac := math.Modf(v)
+			if frac != 0 {
+				return nil, fmt.Errorf("%w, value has fractional part", errImpossible)
+			}
+
+			return int(intPart), nil
+		case string:
+			intVal, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w, %s", errImpossible, err)
+			}
+
+			return intVal, nil
+		}
+	case "float":
+		switch v := iValue.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			floatVal, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w, %s", errImpossible, err)
+			}
+
+			return floatVal, nil
+		}
+	case "bool":
+		switch v := iValue.(type) {
+		case bool:
+			return v, nil
+		case string:
+			boolVal, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w, %s", errImpossible, err)
+			}
+
+			return boolVal, nil
+		}
+	default:
+		return nil, fmt.Errorf("%s is unknown type for this step", goType)
+	}
+
+	return nil, errImpossible
+}
+
+//resolveJSONNodeDate returns date from JSON node of last response body.
+//Node should be string with date in one of accepted layouts.
+func (s *Scenario) resolveJSONNodeDate(expr string) (time.Time, error) {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	date, err := parseDate(strVal, s.dateLayouts)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("node %s: %w", expr, err)
+	}
+
+	return date, nil
+}
+
+//resolveJSONString returns string value of JSON node from last response body
+func (s *Scenario) resolveJSONString(expr string) (string, error) {
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return "", err
+	}
+
+	strVal, ok := iValue.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: node %s is %T, expected string", ErrJsonNode, expr, iValue)
+	}
+
+	return strVal, nil
+}
+
+//resolveJSONURL returns URL from string JSON node from last response body, URL should be absolute with scheme and host
+func (s *Scenario) resolveJSONURL(expr string) (*url.URL, error) {
+	strVal, err := s.resolveJSONString(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeURL, err := url.Parse(strVal)
+	if err != nil {
+		return nil, fmt.Errorf("node %s value %q is not valid URL: %w", expr, strVal, err)
+	}
+
+	if nodeURL.Scheme == "" || nodeURL.Host == "" {
+		return nil, fmt.Errorf("node %s value %q is not absolute URL with scheme and host", expr, strVal)
+	}
+
+	return nodeURL, nil
+}
+
+//resolveJSONNumber returns numeric value of JSON node from last response body
+func (s *Scenario) resolveJSONNumber(expr string) (float64, error) {
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return 0, err
+	}
+
+	number, ok := iValue.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: node %s is %T, expected number", ErrJsonNode, expr, iValue)
+	}
+
+	return number, nil
+}
+
+//responseCookie returns cookie set by last HTTP response with given name
+func (s *Scenario) responseCookie(name string) (*http.Cookie, error) {
+	cookies := s.lastResponse.Cookies()
+	names := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie, nil
+		}
+
+		names = append(names, cookie.Name)
+	}
+
+	return nil, fmt.Errorf("last HTTP response does not set cookie %s, set cookies: %s", name, strings.Join(names, ", "))
+}
+
+//sameSiteName returns value of SameSite cookie attribute, empty if attribute is not set
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteNoneMode:
+		return "None"
+	default:
+		return ""
+	}
+}
+
+//numericHeader returns value of last HTTP response header parsed as number
+func (s *Scenario) numericHeader(name string) (float64, error) {
+	header := s.lastResponse.Header.Get(name)
+	if header == "" {
+		return 0, fmt.Errorf("could not find header %s in last HTTP response", name)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(header), 64)
+	if err != nil {
+		return 0, fmt.Errorf("header %s value %q is not number", name, header)
+	}
+
+	return value, nil
+}
+
+//resolveJSONSliceSubValues resolves JSON node from last response body, which should be slice,
+//and returns value pointed by subPath in each of its elements. Empty subPath points at element itself.
+func (s *Scenario) resolveJSONSliceSubValues(expr, subPath string) ([]interface{}, error) {
+	iValue, err := qjson.Resolve(expr, s.GetLastResponseBody())
+	if err != nil {
+		if s.isDebug {
+			s.debugLastResponseBody()
+		}
+
+		return nil, err
+	}
+
+	elements, ok := iValue.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: node %s is %T, expected slice", ErrJsonNode, expr, iValue)
+	}
+
+	values := make([]interface{}, 0, len(elements))
+	for i, element := range elements {
+		if subPath == "" {
+			values = append(values, element)
+			continue
+		}
+
+		elementBytes, err := json.Marshal(element)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := qjson.Resolve(subPath, elementBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s[%d] has no node %s", ErrJsonNode, expr, i, subPath)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+//matchJSONExample checks whether actual JSON value has structure and types of example JSON value.
+//Objects should have all keys of example object, additional keys are allowed.
+//Every element of slice should match first element of example slice, empty example slice matches any slice.
+//Null in example matches any value. Path points at compared values and is used in error messages.
+func matchJSONExample(example, actual interface{}, path string) error {
+	node := path
+	if node == "" {
+		node = "root node"
+	}
+
+	switch exampleValue := example.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		actualValue, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is %s, expected map", node, jsonTypeName(actual))
+		}
+
+		keys := make([]string, 0, len(exampleValue))
+		for key := range exampleValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			value, ok := actualValue[key]
+			if !ok {
+				return fmt.Errorf("missing key %s", keyPath)
+			}
+
+			if err := matchJSONExample(exampleValue[key], value, keyPath); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case []interface{}:
+		actualValue, ok := actual.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is %s, expected slice", node, jsonTypeName(actual))
+		}
+
+		if len(exampleValue) == 0 {
+			return nil
+		}
+
+		for i, element := range actualValue {
+			if err := matchJSONExample(exampleValue[0], element, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		if jsonTypeName(example) != jsonTypeName(actual) {
+			return fmt.Errorf("%s is %s, expected %s", node, jsonTypeName(actual), jsonTypeName(example))
+		}
+
+		return nil
+	}
+}
+
+//jsonTypeName returns name of type of unmarshalled JSON value
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "slice"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+//jsonPathStep is single step of expression pointing at JSON node, either object key or slice index
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+//parseJSONPath splits expression in format accepted by qjson package, e.g. data[1].user.name, into steps
+func parseJSONPath(expr string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	for _, part := range strings.Split(expr, ".") {
+		key := part
+		if bracket := strings.Index(part, "["); bracket != -1 {
+			key = part[:bracket]
+		}
+
+		if key != "" {
+			steps = append(steps, jsonPathStep{key: key})
+		}
+
+		rest := part[len(key):]
+		for rest != "" {
+			closing := strings.Index(rest, "]")
+			if rest[0] != '[' || closing == -1 {
+				return nil, fmt.Errorf("invalid expression %s", expr)
+			}
+
+			index, err := strconv.Atoi(rest[1:closing])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in expression %s", expr)
+			}
+
+			steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			rest = rest[closing+1:]
+		}
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid expression %s", expr)
+	}
+
+	return steps, nil
+}
+
+//setJSONNode replaces value of node pointed by expr in unmarshalled JSON root with value.
+//It returns false if node does not exist.
+func setJSONNode(root interface{}, expr string, value interface{}) (bool, error) {
+	steps, err := parseJSONPath(expr)
+	if err != nil {
+		return false, err
+	}
+
+	current := root
+	for i, step := range steps {
+		isLast := i == len(steps)-1
+		if step.isIndex {
+			slice, ok := current.([]interface{})
+			if !ok || step.index >= len(slice) {
+				return false, nil
+			}
+
+			if isLast {
+				slice[step.index] = value
+				return true, nil
+			}
+
+			current = slice[step.index]
+			continue
+		}
+
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+
+		next, ok := object[step.key]
+		if !ok {
+			return false, nil
+		}
+
+		if isLast {
+			object[step.key] = value
+			return true, nil
+		}
+
+		current = next
+	}
+
+	return false, nil
+}
+
+//parseTemplatedDate replaces template values in provided string and parses it as date
+func (s *Scenario) parseTemplatedDate(dateTemplate string) (time.Time, error) {
+	replaced, err := s.replaceTemplatedValue(dateTemplate)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return parseDate(replaced, s.dateLayouts)
+}
+
+//parseDate parses value using first matching layout from provided layouts
+func parseDate(value string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%s is not date in any of layouts: %s", value, strings.Join(layouts, ", "))
+}
+
+//countInLastResponseBody returns number of non-overlapping occurrences of substring in last response body
+func (s *Scenario) countInLastResponseBody(substring string) (int, error) {
+	if substring == "" {
+		return 0, errors.New("substring can't be empty")
+	}
+
+	return bytes.Count(s.GetLastResponseBody(), []byte(substring)), nil
+}
+
+//isHTML checks whether provided body starts with HTML doctype or <html> tag,
+//leading BOM and white characters are ignored
+func isHTML(body []byte) bool {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	lowered := bytes.ToLower(trimmed)
+
+	return bytes.HasPrefix(lowered, []byte("<!doctype html")) || bytes.HasPrefix(lowered, []byte("<html"))
+}
+
 //theResponseShouldBeInJSON checks if last response body is in JSON format.
 func (s *Scenario) theResponseShouldBeInJSON() error {
 	var js map[string]interface{}
@@ -83,3 +1175,89 @@ func (s *Scenario) theResponseShouldBeInJSON() error {
 
 	return fmt.Errorf("response has %w", ErrJson)
 }
+
+//theResponseShouldBeInXML checks if last response body is in XML format.
+func (s *Scenario) theResponseShouldBeInXML() error {
+	if isXML(s.GetLastResponseBody()) {
+		return nil
+	}
+
+	return errors.New("response is not XML")
+}
+
+//detectContentType returns value for Content-Type header matching format of provided body.
+//JSON and XML are recognised explicitly, for anything else http.DetectContentType is used.
+func detectContentType(body []byte) string {
+	if json.Valid(body) {
+		return "application/json"
+	}
+
+	if isXML(body) {
+		return "application/xml"
+	}
+
+	return http.DetectContentType(body)
+}
+
+//isXML checks whether provided slice of bytes is well-formed XML document with at least one element
+func isXML(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	hasElement := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return hasElement
+		}
+
+		if err != nil {
+			return false
+		}
+
+		if _, ok := token.(xml.StartElement); ok {
+			hasElement = true
+		}
+	}
+}
+
+//setDifferences compares expected and actual values as sets of trimmed strings and returns sorted values
+//missing in actual and sorted values of actual not present in expected. Empty values are ignored.
+//If ignoreCase is true, values are compared case-insensitively
+func setDifferences(expected, actual []string, ignoreCase bool) ([]string, []string) {
+	toSet := func(values []string) map[string]string {
+		set := map[string]string{}
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+
+			key := value
+			if ignoreCase {
+				key = strings.ToLower(value)
+			}
+			set[key] = value
+		}
+
+		return set
+	}
+
+	expectedSet, actualSet := toSet(expected), toSet(actual)
+
+	var missing, unexpected []string
+	for key, value := range expectedSet {
+		if _, ok := actualSet[key]; !ok {
+			missing = append(missing, value)
+		}
+	}
+
+	for key, value := range actualSet {
+		if _, ok := expectedSet[key]; !ok {
+			unexpected = append(unexpected, value)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+
+	return missing, unexpected
+}
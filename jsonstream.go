@@ -0,0 +1,111 @@
+package gdutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+//countStreamedJSONArray counts elements of array pointed by steps in JSON document read from r, without loading
+//whole document into memory. Counting stops after atLeast elements, so rest of document is not read.
+//Empty steps point at root of document.
+func countStreamedJSONArray(r io.Reader, steps []jsonPathStep, atLeast int) (int, error) {
+	decoder := json.NewDecoder(r)
+	for _, step := range steps {
+		if step.isIndex {
+			if err := expectJSONDelim(decoder, '['); err != nil {
+				return 0, err
+			}
+
+			for i := 0; i < step.index; i++ {
+				if !decoder.More() {
+					return 0, fmt.Errorf("%w: array has no element at index %d", ErrJsonNode, step.index)
+				}
+
+				if err := skipJSONValue(decoder); err != nil {
+					return 0, err
+				}
+			}
+
+			if !decoder.More() {
+				return 0, fmt.Errorf("%w: array has no element at index %d", ErrJsonNode, step.index)
+			}
+
+			continue
+		}
+
+		if err := expectJSONDelim(decoder, '{'); err != nil {
+			return 0, err
+		}
+
+		for {
+			if !decoder.More() {
+				return 0, fmt.Errorf("%w: object has no key %s", ErrJsonNode, step.key)
+			}
+
+			token, err := decoder.Token()
+			if err != nil {
+				return 0, err
+			}
+
+			if token == step.key {
+				break
+			}
+
+			if err = skipJSONValue(decoder); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := expectJSONDelim(decoder, '['); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for count < atLeast && decoder.More() {
+		if err := skipJSONValue(decoder); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+//expectJSONDelim reads next token from decoder and returns error if it is not expected delimiter
+func expectJSONDelim(decoder *json.Decoder, expected json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("%w: expected %s, got %v", ErrJsonNode, expected, token)
+	}
+
+	return nil
+}
+
+//skipJSONValue reads next JSON value from decoder token by token and discards it
+func skipJSONValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
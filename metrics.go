@@ -0,0 +1,21 @@
+package gdutils
+
+import (
+	"net/http"
+	"time"
+)
+
+//MetricsObserver receives metrics of HTTP requests sent by Scenario, e.g. to export them as timing histograms.
+type MetricsObserver interface {
+	//RequestStarted is called right before req is sent.
+	RequestStarted(req *http.Request)
+	//ResponseReceived is called after response to req is received.
+	//Argument duration is time between sending request and receiving response, bodyBytes is length of response body.
+	ResponseReceived(req *http.Request, statusCode int, duration time.Duration, bodyBytes int)
+}
+
+//SetMetricsObserver sets observer notified about every HTTP request sent by Scenario.
+//Metrics observer is preserved between scenarios.
+func (s *Scenario) SetMetricsObserver(obs MetricsObserver) {
+	s.metricsObserver = obs
+}
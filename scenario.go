@@ -2,8 +2,16 @@ package gdutils
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"os"
+	"time"
 )
 
 //Scenario struct represents data shared across one scenario.
@@ -12,15 +20,155 @@ type Scenario struct {
 	cache map[string]interface{}
 	//lastResponse holds last HTTP response
 	lastResponse *http.Response
+	//previousResponse holds HTTP response received before last response, with buffered body
+	previousResponse *http.Response
 	//isDebug determine whether scenario should be run under debug mode
 	isDebug bool
+	//debugOutput is destination of debug messages, by default it is os.Stdout
+	debugOutput io.Writer
+	//structuredDebug determine whether debug messages should be written as JSON lines
+	structuredDebug bool
+	//colorizedDebug determine whether diffs written to debug output should be colored
+	colorizedDebug bool
+	//dateLayouts holds layouts accepted by steps parsing dates, by default it is only time.RFC3339
+	dateLayouts []string
+	//debugFile holds file opened by ISetDebugOutputToFile, it is closed when debug output changes
+	debugFile *os.File
+	//roundTripper is transport used to send HTTP requests, by default it is transport returned by DefaultTransport
+	roundTripper http.RoundTripper
+	//metricsObserver is notified about sent HTTP requests, it is optional
+	metricsObserver MetricsObserver
+	//waitJitterPercent is maximal percentage of random jitter applied to waits
+	waitJitterPercent int
+	//random is source of randomness for jitter, by default package wide seeded source is used
+	random *rand.Rand
+	//clock returns current time used by date and timer steps, by default it is time.Now
+	clock func() time.Time
+	//autoContentType determine whether Content-Type header should be set on outgoing requests based on their body
+	autoContentType bool
+	//requestTimeout is maximal duration of sending request and reading its response, 0 means no timeout
+	requestTimeout time.Duration
+	//maxResponseBodySize is maximal number of bytes of response body read, 0 means no limit
+	maxResponseBodySize int64
+	//nextQueryParams are added to query of next sent request, they are used once
+	nextQueryParams url.Values
+	//nextRemovedHeaders are removed from next sent request, they are used once
+	nextRemovedHeaders []string
+	//nextCookies are added to next sent request, they are used once
+	nextCookies []*http.Cookie
+	//basicAuth holds username and password set as HTTP Basic Auth of sent requests, it is not set if it is nil
+	basicAuth *[2]string
+	//awsSigV4 holds credentials used to sign sent requests with AWS Signature Version 4, requests are not signed if it is nil
+	awsSigV4 *awsSigV4Credentials
+	//lastCanonicalRequest holds canonical form of last request signed with AWS Signature Version 4
+	lastCanonicalRequest string
+	//dryRunResponseBody is body of responses returned instead of sending requests, requests are sent if it is nil
+	dryRunResponseBody []byte
+	//cannedResponse is returned instead of sending next request, it is used once
+	cannedResponse *dryRunTransport
+	//harReplay responds to requests with responses from HAR file instead of sending them, requests are sent if it is nil
+	harReplay *harReplayTransport
+	//skipGuarded determine whether steps wrapped with Guard should be skipped
+	skipGuarded bool
+	//responseExamples holds unmarshalled JSON examples registered by RegisterResponseExample, keyed by example name
+	responseExamples map[string]interface{}
 }
 
 //ResetScenario resets Scenario struct instance to default values.
 func (s *Scenario) ResetScenario(isDebug bool) {
 	s.cache = map[string]interface{}{}
 	s.lastResponse = &http.Response{}
+	s.previousResponse = nil
 	s.isDebug = isDebug
+	s.autoContentType = false
+	s.structuredDebug = false
+	s.colorizedDebug = false
+	s.waitJitterPercent = 0
+	s.maxResponseBodySize = 0
+	s.requestTimeout = 0
+	s.nextQueryParams = nil
+	s.nextRemovedHeaders = nil
+	s.nextCookies = nil
+	s.basicAuth = nil
+	s.awsSigV4 = nil
+	s.lastCanonicalRequest = ""
+	s.dryRunResponseBody = nil
+	s.cannedResponse = nil
+	s.harReplay = nil
+	s.skipGuarded = false
+	if s.debugOutput == nil {
+		s.debugOutput = os.Stdout
+	}
+
+	if len(s.dateLayouts) == 0 {
+		s.dateLayouts = []string{time.RFC3339}
+	}
+
+	if s.roundTripper == nil {
+		s.roundTripper = DefaultTransport()
+	}
+}
+
+//DefaultTransport returns transport used by Scenario to send HTTP requests when no other is set.
+//It does not verify server TLS certificates.
+func DefaultTransport() http.RoundTripper {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+}
+
+//SetRandSource sets source of randomness used for jitter, e.g. source with constant seed for reproducible runs.
+//Random source is preserved between scenarios.
+func (s *Scenario) SetRandSource(src rand.Source) {
+	s.random = rand.New(src)
+}
+
+//SetClock sets source of current time used by steps comparing dates with now, flow timer and request signing,
+//e.g. function returning fixed time for deterministic scenarios. Clock is preserved between scenarios.
+func (s *Scenario) SetClock(clock func() time.Time) {
+	s.clock = clock
+}
+
+//SetRoundTripper sets transport used to send HTTP requests, e.g. middleware adding retries or metrics.
+//Provided rt replaces built-in transport entirely, so it is last in chain and responsible for sending request.
+//To keep built-in behaviour, rt should delegate to transport returned by DefaultTransport.
+//Round tripper is preserved between scenarios.
+func (s *Scenario) SetRoundTripper(rt http.RoundTripper) {
+	s.roundTripper = rt
+}
+
+//SetDateLayouts sets layouts accepted by steps parsing dates, layouts are tried in provided order.
+//Date layouts are preserved between scenarios.
+func (s *Scenario) SetDateLayouts(layouts ...string) {
+	s.dateLayouts = layouts
+}
+
+//SetDebugOutput sets destination of debug messages.
+//Debug output is preserved between scenarios.
+func (s *Scenario) SetDebugOutput(w io.Writer) {
+	if s.debugFile != nil {
+		_ = s.debugFile.Close()
+		s.debugFile = nil
+	}
+
+	s.debugOutput = w
+}
+
+//RegisterResponseExample registers JSON example of response under given name, so it may be used by TheResponseShouldMatchExample.
+//Response examples are preserved between scenarios.
+func (s *Scenario) RegisterResponseExample(name, jsonExample string) error {
+	var example interface{}
+	if err := json.Unmarshal([]byte(jsonExample), &example); err != nil {
+		return fmt.Errorf("example %s has %w: %v", name, ErrJson, err)
+	}
+
+	if s.responseExamples == nil {
+		s.responseExamples = map[string]interface{}{}
+	}
+
+	s.responseExamples[name] = example
+
+	return nil
 }
 
 //Save preserve value under given key in cache.
@@ -39,6 +187,51 @@ func (s *Scenario) GetSaved(key string) (interface{}, error) {
 	return val, nil
 }
 
+//GetSavedString returns preserved value from cache if it is present and is string, error otherwise.
+func (s *Scenario) GetSavedString(key string) (string, error) {
+	val, err := s.GetSaved(key)
+	if err != nil {
+		return "", err
+	}
+
+	strVal, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: value under key %s is %T, expected string", ErrPreservedData, key, val)
+	}
+
+	return strVal, nil
+}
+
+//GetSavedInt returns preserved value from cache if it is present and is int, error otherwise.
+func (s *Scenario) GetSavedInt(key string) (int, error) {
+	val, err := s.GetSaved(key)
+	if err != nil {
+		return 0, err
+	}
+
+	intVal, ok := val.(int)
+	if !ok {
+		return 0, fmt.Errorf("%w: value under key %s is %T, expected int", ErrPreservedData, key, val)
+	}
+
+	return intVal, nil
+}
+
+//GetSavedTime returns preserved value from cache if it is present and is time.Time, error otherwise.
+func (s *Scenario) GetSavedTime(key string) (time.Time, error) {
+	val, err := s.GetSaved(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	timeVal, ok := val.(time.Time)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: value under key %s is %T, expected time.Time", ErrPreservedData, key, val)
+	}
+
+	return timeVal, nil
+}
+
 //GetLastResponseBody returns last HTTP response body as slice of bytes
 //method is safe for multiple use
 func (s *Scenario) GetLastResponseBody() []byte {
@@ -48,3 +241,14 @@ func (s *Scenario) GetLastResponseBody() []byte {
 
 	return bodyBytes
 }
+
+//GetLastResponseAsJSON returns last HTTP response body unmarshalled to map
+//method is safe for multiple use, error is returned if body is not JSON object, including top-level JSON array
+func (s *Scenario) GetLastResponseAsJSON() (map[string]interface{}, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(s.GetLastResponseBody(), &body); err != nil {
+		return nil, fmt.Errorf("last response body has %w: %s", ErrJson, err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,177 @@
+package gdutils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	//awsSigV4Algorithm is name of AWS Signature Version 4 signing algorithm
+	awsSigV4Algorithm = "AWS4-HMAC-SHA256"
+	//awsSigV4DateLayout is layout of X-Amz-Date header
+	awsSigV4DateLayout = "20060102T150405Z"
+)
+
+//awsSigV4Credentials holds data required to sign HTTP requests with AWS Signature Version 4.
+type awsSigV4Credentials struct {
+	accessKey string
+	secretKey string
+	region    string
+	service   string
+}
+
+//signAWSSigV4 signs req with AWS Signature Version 4 at time t, it sets X-Amz-Date and Authorization headers.
+//Every header present in req and Host are signed, except Authorization, which is replaced by signature. It returns canonical request used to compute signature.
+func signAWSSigV4(req *http.Request, creds awsSigV4Credentials, t time.Time) (string, error) {
+	payload := []byte{}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+
+		payload, err = ioutil.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+	} else if req.Body != nil && req.Body != http.NoBody {
+		return "", errors.New("streamed request body can not be signed with AWS SigV4")
+	}
+
+	amzDate := t.UTC().Format(awsSigV4DateLayout)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	canonicalRequest, signedHeaders := awsSigV4CanonicalRequest(req, payload, creds.service)
+	scope := strings.Join([]string{amzDate[:8], creds.region, creds.service, "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{awsSigV4Algorithm, amzDate, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	signingKey := []byte("AWS4" + creds.secretKey)
+	for _, part := range []string{amzDate[:8], creds.region, creds.service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigV4Algorithm, creds.accessKey, scope, signedHeaders, signature))
+
+	return canonicalRequest, nil
+}
+
+//awsSigV4CanonicalRequest returns canonical form of req with payload sent to service and list of signed header names
+func awsSigV4CanonicalRequest(req *http.Request, payload []byte, service string) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		//Authorization header is replaced by signature, so it is not signed
+		if strings.EqualFold(name, "Authorization") {
+			continue
+		}
+
+		trimmed := make([]string, 0, len(values))
+		for _, value := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(value), " "))
+		}
+
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	signedHeaders := strings.Join(names, ";")
+
+	return strings.Join([]string{
+		req.Method,
+		awsSigV4CanonicalURI(req.URL, service),
+		awsSigV4CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n"), signedHeaders
+}
+
+//awsSigV4CanonicalURI returns path of u with every segment encoded according to RFC 3986.
+//Segments are encoded twice for every service except S3, which expects them encoded once
+func awsSigV4CanonicalURI(u *url.URL, service string) string {
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+
+		segment = awsSigV4Escape(segment)
+		if service != "s3" {
+			segment = awsSigV4Escape(segment)
+		}
+
+		segments[i] = segment
+	}
+
+	path := strings.Join(segments, "/")
+	if path == "" {
+		return "/"
+	}
+
+	return path
+}
+
+//awsSigV4CanonicalQuery returns query parameters sorted by name and value, encoded according to RFC 3986
+func awsSigV4CanonicalQuery(query url.Values) string {
+	params := make([][2]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, [2]string{awsSigV4Escape(name), awsSigV4Escape(value)})
+		}
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+
+		return params[i][1] < params[j][1]
+	})
+
+	encoded := make([]string, 0, len(params))
+	for _, param := range params {
+		encoded = append(encoded, param[0]+"="+param[1])
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+//awsSigV4Escape encodes s according to RFC 3986, url.QueryEscape alone encodes space as plus sign
+func awsSigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+//hmacSHA256 returns HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,170 @@
+package gdutils
+
+import (
+	"reflect"
+	"sort"
+)
+
+//StepInfo holds metadata of single step method of Scenario.
+type StepInfo struct {
+	//Name is name of Scenario method implementing step
+	Name string
+	//Description tells what step does
+	Description string
+	//ArgTypes holds Go types of step method arguments in order
+	ArgTypes []string
+}
+
+//nonStepMethods holds names of exported Scenario methods returning only error, which are part of Go API, not steps
+var nonStepMethods = map[string]bool{
+	"RegisterResponseExample": true,
+}
+
+//stepDescriptions is registry of descriptions of Scenario step methods, keyed by method name.
+//Every new step method should be registered here.
+var stepDescriptions = map[string]string{
+	"ISendRequestToWithBodyAndHeaders":                                  "sends HTTP request with provided body and headers",
+	"ISendRequestToWithBodyAndHeadersAndDeadlineFrom":                   "sends HTTP request with provided body and headers, cancelled when deadline from cache is reached",
+	"IEnableContentTypeAutoDetection":                                   "turns on setting Content-Type header of next requests based on their JSON, XML or other body format",
+	"ISetStructuredDebug":                                               "turns on or off writing debug messages as JSON lines",
+	"ISetColorizedDebug":                                                "turns on or off coloring of diffs written to debug output",
+	"ISetDebugOutputToFile":                                             "redirects debug messages to file",
+	"TheResponseStatusCodeShouldBe":                                     "checks last response status code",
+	"TheResponseShouldBeIn":                                             "checks whether last response body has given data format",
+	"ISaveFromTheLastResponseJSONNodeAs":                                "saves JSON node from last response body in cache",
+	"IGenerateARandomIntInTheRangeToAndSaveItAs":                        "generates random int from range and saves it in cache",
+	"IGenerateARandomFloatInTheRangeToAndSaveItAs":                      "generates random float from range and saves it in cache",
+	"IGenerateARandomStringOfLengthWithoutUnicodeCharactersAndSaveItAs": "generates random string without unicode characters and saves it in cache",
+	"IGenerateARandomStringOfLengthWithUnicodeCharactersAndSaveItAs":    "generates random string with unicode characters and saves it in cache",
+	"TheJSONResponseShouldHaveKey":                                      "checks whether last response body has JSON key",
+	"TheJSONResponseShouldHaveKeys":                                     "checks whether last response body has comma separated JSON keys",
+	"TheJSONNodeShouldBe":                                               "checks whether JSON node from last response body is of given type",
+	"TheJSONNodeShouldNotBe":                                            "checks whether JSON node from last response body is not of given type",
+	"TheJSONNodeShouldBeSliceOfLength":                                  "checks whether JSON node from last response body is slice of given length",
+	"TheJSONNodeShouldBeOfValue":                                        "checks whether JSON node from last response body has given type and value",
+	"TheJSONNodeDateShouldBeBetween":                                    "checks whether JSON node from last response body is date within range",
+	"TheJSONNodeDateShouldBeWithinOfNow":                                "checks whether JSON node from last response body is date close to current time",
+	"TheCachedValueShouldBeOfType":                                      "checks whether cached value is of given type",
+	"ICastCachedValueToAs":                                              "converts cached value to given type and saves it under new key",
+	"IPrintLastResponseBody":                                            "prints last response body",
+	"IWait":                                                             "waits for given amount of time",
+	"TheResponseShouldHaveHeader":                                       "checks whether last response has header",
+	"TheResponseShouldHaveHeaderOfValue":                                "checks whether last response has header with given value",
+	"TheResponseBodyShouldNotBeHTML":                                    "checks whether last response body is not HTML document",
+	"TheDetectedContentTypeShouldBe":                                    "checks content type of last response body detected by http.DetectContentType",
+	"TheResponseImageDimensionsShouldBe":                                "checks width and height of image from last response body",
+	"ISaveRegexCaptureFromResponseBodyAs":                               "saves value captured by named regex group from last response body in cache",
+	"TheResponseBodyShouldContainSubstringTimes":                        "checks number of occurrences of substring in last response body",
+	"TheResponseBodyShouldContainSubstringAtLeastTimes":                 "checks minimal number of occurrences of substring in last response body",
+	"TheJSONNodeShouldEqualNode":                                        "checks whether two JSON nodes from last response body are equal",
+	"TheJSONNodeShouldEqualSumOfNodes":                                  "checks whether numeric JSON node from last response body is equal to sum of other nodes",
+	"TheJSONNodeStringShouldHaveLength":                                 "checks length in runes of string JSON node from last response body",
+	"TheJSONNodeStringShouldHaveLengthBetween":                          "checks whether length in runes of string JSON node from last response body is within range",
+	"TheJSONNodeShouldEqualCachedValue":                                 "checks whether JSON node from last response body is equal to cached value",
+	"TheResponseShouldHaveBeenServedOverHTTPS":                          "checks whether last response was served over HTTPS",
+	"TheResponseShouldHaveBeenServedOverHTTP":                           "checks whether last response was served over plain HTTP",
+	"ISendRequestToWithBodyAndHeadersWithBackoff":                       "sends HTTP request with provided body and headers, retrying on network errors with exponential backoff",
+	"ISetWaitJitter":                                                    "sets random jitter applied to waits and retry delays",
+	"TheJSONNodeShouldBeOfValueFromFile":                                "checks whether JSON node from last response body is equal to node from JSON file",
+	"IGenerateIdempotencyKeyAndSaveItAs":                                "generates random UUID and saves it in cache",
+	"ISendRequestToWithBodyAndHeadersTwice":                             "sends the same HTTP request two times in a row",
+	"TheTwoResponsesShouldBeIdentical":                                  "checks whether last two responses have the same status code and body",
+	"IStartFlowTimer":                                                   "starts measuring time of flow spanning multiple steps",
+	"TheFlowShouldHaveTakenLessThan":                                    "checks whether time elapsed since flow timer start is less than given duration",
+	"TheJSONNodeShouldBeOneOfValues":                                    "checks whether JSON node from last response body is equal to one of values",
+	"TheJSONResponseShouldNotHaveKeys":                                  "checks whether last response body has none of comma separated JSON keys",
+	"ISaveLastResponseBodyRedactedAs":                                   "saves in cache last response body with given JSON nodes redacted",
+	"TheJSONNodeSliceShouldBeSortedBy":                                  "checks whether JSON slice elements are sorted by given node",
+	"TheJSONNodeSliceElementsShouldHaveUnique":                          "checks whether given node is unique across JSON slice elements",
+	"TheResponseShouldMatchExample":                                     "checks whether last response body has structure and types of registered JSON example",
+	"ISetMaxResponseBodySize":                                           "sets maximal size in bytes of response bodies read in current scenario",
+	"TheResponseBodyShouldEqualFile":                                    "checks whether raw bytes of last response body are equal to file content",
+	"TheResponseBodyShouldBeFileType":                                   "checks whether last response body starts with magic number of given file type",
+	"TheResponseShouldNotHaveRedirectedToDifferentHost":                 "checks whether last response was not redirected to host other than requested",
+	"ILoadEnvironmentConfigFromFile":                                    "saves in cache values of given environment from JSON file, YAML is not supported",
+	"TheResponseCompressionRatioShouldBeAtLeast":                        "checks whether last response body compression ratio is at least given value",
+	"ISaveETagAs":                                                       "saves ETag header of last response in cache",
+	"TheETagShouldEqualCached":                                          "checks whether ETag header of last response is equal to cached ETag",
+	"TheResponseVaryHeaderShouldContain":                                "checks whether Vary header of last response lists given header",
+	"TheResponseUpstreamHitCountHeaderShouldBe":                         "checks whether header of last response counting upstream hits has given value",
+	"TheResponseShouldHaveNumericHeaderOfValue":                         "checks whether header of last response is number of given value",
+	"TheResponseShouldHaveNumericHeaderGreaterThan":                     "checks whether header of last response is number greater than given value",
+	"TheResponseShouldHaveNumericHeaderLessThan":                        "checks whether header of last response is number less than given value",
+	"TheResponseContentLengthShouldMatchBody":                           "checks whether Content-Length of last response is equal to its body size",
+	"TheStreamedJSONLinesNodeShouldBeIncreasing":                        "checks whether numeric node never decreases across JSON lines streamed for given time",
+	"ISetAWSSigV4Signing":                                               "turns on signing of next requests with AWS Signature Version 4",
+	"IPrintCanonicalRequest":                                            "writes to debug output canonical form of last request signed with AWS SigV4",
+	"TheResponseShouldHaveHeaderValues":                                 "checks whether all values of repeated header of last response are equal to given values",
+	"TheJSONNodeSliceDatesShouldBeAscending":                            "checks whether dates of JSON slice elements are in chronological order",
+	"TheJSONResponseShouldSatisfy":                                      "checks whether JSON node from last response body is truthy",
+	"IExportCacheToJUnitProperties":                                     "writes values from cache under selected keys to file as JUnit properties XML",
+	"TheTwoResponsesShouldDiffer":                                       "checks whether bodies of last two responses are different",
+	"IEnableDryRun":                                                     "turns on building requests without sending them, responses have status 200 and empty body",
+	"IEnableDryRunWithResponseBody":                                     "turns on building requests without sending them, responses have status 200 and given body",
+	"ISetCannedResponseForNextSend":                                     "makes next sent request return given response without network communication",
+	"IReplayFromHARFile":                                                "makes sent requests return responses recorded in HAR file",
+	"TheRequestQueryParamShouldBe":                                      "checks whether query parameter of last sent request has given value",
+	"TheJSONNodeURLDecodedShouldBe":                                     "checks whether percent-decoded JSON node from last response body has given value",
+	"TheJSONNodeBase64DecodedShouldBe":                                  "checks whether base64 decoded JSON node from last response body has given value",
+	"TheJSONNodeBase64DecodedShouldHaveFormat":                          "checks whether base64 decoded JSON node from last response body has given data format",
+	"ISaveJSONNodeBase64DecodedAs":                                      "saves in cache base64 decoded JSON node from last response body",
+	"ISendRequestToTimesAndAssertP95":                                   "sends HTTP request given number of times and checks 95th percentile of durations",
+	"ISkipGuardedStepsIfCachedValueIsFalse":                             "starts block in which guarded steps are skipped if cached bool value is false",
+	"IStopSkippingGuardedSteps":                                         "ends block in which guarded steps are skipped",
+	"TheCreatedResourceShouldBeRetrievable":                             "sends GET request to Location header of last response and checks its status code",
+	"TheJSONNodeShouldEqualHashOfCachedValue":                           "checks whether JSON node from last response body is hash of cached value",
+	"TheResponseCookieShouldBeSecure":                                   "checks whether cookie set by last response has Secure attribute",
+	"TheResponseCookieShouldBeHttpOnly":                                 "checks whether cookie set by last response has HttpOnly attribute",
+	"TheResponseCookieShouldBeSameSite":                                 "checks whether cookie set by last response has given SameSite attribute",
+	"TheJSONNodeShouldBeValidURL":                                       "checks whether JSON node from last response body is absolute URL",
+	"TheJSONNodeShouldBeValidURLAndShouldBeReachable":                   "checks whether JSON node from last response body is absolute URL responding with 2xx to HEAD request",
+	"IFollowJSONNodeLink":                                               "sends GET request to URL from JSON node of last response body",
+	"IComputeAndSaveAs":                                                 "evaluates arithmetic expression of cached numeric values and saves result in cache",
+	"ISetRequestTimeout":                                                "sets timeout of next requests in current scenario",
+	"TheJSONNodeShouldBeOfValueGreaterThan":                             "checks whether numeric JSON node from last response body is greater than given value",
+	"TheJSONNodeShouldBeOfValueLessThan":                                "checks whether numeric JSON node from last response body is less than given value",
+	"ISaveCanonicalJSONOfResponseAs":                                    "saves canonical form (RFC 8785) of last response body in cache",
+	"TheCanonicalResponseShouldEqualCached":                             "checks whether canonical form (RFC 8785) of last response body is equal to cached one",
+	"ISaveFromTheLastResponseHeaderAs":                                  "saves value of header of last response in cache",
+	"TheResponseHeaderShouldBeSet":                                      "checks whether comma separated members of header of last response are equal to given set",
+	"ISetBasicAuth":                                                     "sets HTTP Basic Auth on next requests in current scenario",
+	"TheStreamedJSONArrayAtShouldHaveAtLeastElements":                   "checks whether streamed JSON array has at least given number of elements, without loading whole body",
+	"ISetFollowingQueryParamsForNextRequest":                            "adds query parameters to URL of next sent request",
+	"ISendMultipartRequestTo":                                           "sends HTTP request with multipart/form-data body built from form fields and files",
+	"TheResponseStatusTextShouldBe":                                     "compares reason phrase of last response status with given text",
+	"IRemoveHeaderFromNextRequest":                                      "removes header from next sent request",
+	"ISetCookieForNextRequest":                                          "adds cookie with templated value to next sent request",
+}
+
+//AvailableSteps returns metadata of every step method of Scenario sorted by method name.
+//Step method is exported method of Scenario that returns only error and is not listed in nonStepMethods.
+func (s *Scenario) AvailableSteps() []StepInfo {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	scenarioType := reflect.TypeOf(s)
+
+	steps := make([]StepInfo, 0, len(stepDescriptions))
+	for i := 0; i < scenarioType.NumMethod(); i++ {
+		method := scenarioType.Method(i)
+		if method.Type.NumOut() != 1 || method.Type.Out(0) != errorType || nonStepMethods[method.Name] {
+			continue
+		}
+
+		//first argument of method expression is receiver
+		argTypes := make([]string, 0, method.Type.NumIn()-1)
+		for j := 1; j < method.Type.NumIn(); j++ {
+			argTypes = append(argTypes, method.Type.In(j).String())
+		}
+
+		steps = append(steps, StepInfo{
+			Name:        method.Name,
+			Description: stepDescriptions[method.Name],
+			ArgTypes:    argTypes,
+		})
+	}
+
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Name < steps[j].Name
+	})
+
+	return steps
+}